@@ -0,0 +1,290 @@
+package input
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/api/option"
+)
+
+// changesMinLag is how far behind the current time the end of a CHANGES
+// window must be, as required by BigQuery.
+const changesMinLag = 10 * time.Minute
+
+type gcpBigQueryChangesInputConfig struct {
+	ProjectID       string
+	DatasetID       string
+	TableID         string
+	CredentialsJSON string
+	Mode            string
+	Interval        time.Duration
+	Lag             time.Duration
+	StartTimestamp  string
+	PageSize        int
+	CheckpointCache string
+	CheckpointKey   string
+}
+
+func gcpBigQueryChangesInputConfigFromParsed(conf *service.ParsedConfig) (cconf gcpBigQueryChangesInputConfig, err error) {
+	if cconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if cconf.ProjectID == "" {
+		cconf.ProjectID = bigquery.DetectProjectID
+	}
+	if cconf.DatasetID, err = conf.FieldString("dataset"); err != nil {
+		return
+	}
+	if cconf.TableID, err = conf.FieldString("table"); err != nil {
+		return
+	}
+	if cconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if cconf.Mode, err = conf.FieldString("mode"); err != nil {
+		return
+	}
+	if cconf.Interval, err = conf.FieldDuration("interval"); err != nil {
+		return
+	}
+	if cconf.Lag, err = conf.FieldDuration("lag"); err != nil {
+		return
+	}
+	if cconf.StartTimestamp, err = conf.FieldString("start_timestamp"); err != nil {
+		return
+	}
+	if cconf.PageSize, err = conf.FieldInt("page_size"); err != nil {
+		return
+	}
+	if cconf.CheckpointCache, err = conf.FieldString("checkpoint", "cache"); err != nil {
+		return
+	}
+	if cconf.CheckpointKey, err = conf.FieldString("checkpoint", "key"); err != nil {
+		return
+	}
+	if cconf.StartTimestamp != "" {
+		if _, err = time.Parse(time.RFC3339Nano, cconf.StartTimestamp); err != nil {
+			err = fmt.Errorf("invalid start_timestamp: %w", err)
+			return
+		}
+	}
+	if cconf.PageSize <= 0 {
+		err = errors.New("page_size must be greater than zero")
+		return
+	}
+	return
+}
+
+func gcpBigQueryChangesConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services").
+		Summary(`Tails the change history of a BigQuery table using the APPENDS or CHANGES table-valued functions.`).
+		Description(`
+Every ` + "`interval`" + ` the changes made to the table since the previous poll are queried and emitted as structured messages, one per changed row. The ` + "`_CHANGE_TYPE`" + ` and ` + "`_CHANGE_TIMESTAMP`" + ` pseudo columns are removed from the rows and set as the ` + "`bigquery_change_type`" + ` and ` + "`bigquery_change_timestamp`" + ` metadata instead.
+
+In ` + "`appends`" + ` mode only inserted rows are read. In ` + "`changes`" + ` mode updates and deletes are read as well, which requires the ` + "`enable_change_history`" + ` option on the table, and each poll only reads changes made at least ten minutes ago, as BigQuery does not allow more recent ones to be queried.
+
+The end of the last poll delivered is checkpointed, in memory or in a cache resource when ` + "`checkpoint.cache`" + ` is set, and the next poll starts from it. Batches rejected downstream are redelivered. Change history can only be read within the time travel window of the table.`).
+		Field(service.NewStringField("project").Description("The project ID of the table. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("dataset").Description("The BigQuery Dataset ID.")).
+		Field(service.NewStringField("table").Description("The table to read the change history of.")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewStringEnumField("mode", "appends", "changes").Description("The table-valued function used to read the change history.").Default("appends")).
+		Field(service.NewDurationField("interval").Description("The time between polls.").Default("1m")).
+		Field(service.NewDurationField("lag").Description("How far behind the current time each poll reads, which gives slow writers time to commit their rows.").Default("0s")).
+		Field(service.NewStringField("start_timestamp").Description("The RFC 3339 timestamp to read changes from until a checkpoint has been stored. Leave empty to start at the beginning of the time travel window.").Example("2024-01-01T00:00:00Z").Default("")).
+		Field(service.NewIntField("page_size").Description("The maximum number of rows read per page, which is also the maximum size of the batches emitted.").Default(1000)).
+		Field(service.NewObjectField("checkpoint",
+			service.NewStringField("cache").Description("The cache resource the checkpoint is stored in. Leave empty to keep it in memory only.").Default(""),
+			service.NewStringField("key").Description("The cache key of the checkpoint. Defaults to a key derived from the table.").Default(""),
+		).Description("Where the end of the last poll delivered is stored."))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_changes", gcpBigQueryChangesConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			cconf, err := gcpBigQueryChangesInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(newGCPBigQueryChangesInput(cconf, mgr)), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type gcpBigQueryChangesInput struct {
+	conf gcpBigQueryChangesInputConfig
+
+	client     *bigquery.Client
+	it         *bigquery.RowIterator
+	start      time.Time
+	end        time.Time
+	loaded     bool
+	nextPoll   time.Time
+	checkpoint *watermark
+
+	mgr *service.Resources
+	log *service.Logger
+}
+
+func newGCPBigQueryChangesInput(conf gcpBigQueryChangesInputConfig, mgr *service.Resources) *gcpBigQueryChangesInput {
+	return &gcpBigQueryChangesInput{
+		conf: conf,
+		mgr:  mgr,
+		log:  mgr.Logger(),
+	}
+}
+
+func (c *gcpBigQueryChangesInput) Connect(ctx context.Context) error {
+	if c.client != nil {
+		return nil
+	}
+	var opts []option.ClientOption
+	if c.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(c.conf.CredentialsJSON)))
+	}
+	client, err := bigquery.NewClient(ctx, c.conf.ProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating big query client: %w", err)
+	}
+	c.client = client
+
+	key := cmp.Or(c.conf.CheckpointKey, fmt.Sprintf("gcp_bigquery_changes/%s/%s/%s", client.Project(), c.conf.DatasetID, c.conf.TableID))
+	c.checkpoint = newWatermark(c.conf.CheckpointCache, key, c.conf.StartTimestamp, c.mgr)
+	return nil
+}
+
+func (c *gcpBigQueryChangesInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if c.client == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+	for {
+		if c.it == nil {
+			it, err := c.poll(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			if it == nil {
+				continue
+			}
+			c.it = it
+		}
+
+		batch, _, err := readQueryPage(c.it, c.conf.PageSize)
+		if err != nil {
+			c.it = nil
+			return nil, nil, err
+		}
+		if len(batch) == 0 {
+			c.it = nil
+			// Advance past the window once all of its pages, and the windows
+			// before it, have been delivered.
+			if err := c.checkpoint.track(c.end.Format(time.RFC3339Nano))(ctx, nil); err != nil {
+				c.log.Warnf("unable to checkpoint changes of %v: %v", c.conf.TableID, err)
+			}
+			c.start = c.end
+			continue
+		}
+		for _, msg := range batch {
+			changeMetadata(msg)
+		}
+		// Until the window has been read completely, a restart must read it
+		// again from its start.
+		var start string
+		if !c.start.IsZero() {
+			start = c.start.Format(time.RFC3339Nano)
+		}
+		return batch, c.checkpoint.track(start), nil
+	}
+}
+
+func (c *gcpBigQueryChangesInput) Close(ctx context.Context) error {
+	if c.client != nil {
+		return c.client.Close()
+	}
+	return nil
+}
+
+// poll waits for the next poll and queries the changes made since the
+// previous one, returning a nil iterator when the window is empty.
+func (c *gcpBigQueryChangesInput) poll(ctx context.Context) (*bigquery.RowIterator, error) {
+	if wait := time.Until(c.nextPoll); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c.nextPoll = time.Now().Add(c.conf.Interval)
+
+	if !c.loaded {
+		start, err := c.checkpoint.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if start != "" {
+			if c.start, err = time.Parse(time.RFC3339Nano, start); err != nil {
+				return nil, fmt.Errorf("invalid checkpoint %q: %w", start, err)
+			}
+		}
+		c.loaded = true
+	}
+
+	lag := c.conf.Lag
+	if c.conf.Mode == "changes" {
+		lag = max(lag, changesMinLag)
+	}
+	end := time.Now().Add(-lag)
+	if !end.After(c.start) {
+		return nil, nil
+	}
+
+	startExpr := "NULL"
+	q := c.client.Query("")
+	if !c.start.IsZero() {
+		startExpr = "@start_timestamp"
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Name: "start_timestamp", Value: c.start})
+	}
+	q.Parameters = append(q.Parameters, bigquery.QueryParameter{Name: "end_timestamp", Value: end})
+	q.Q = fmt.Sprintf("SELECT * FROM %s(TABLE `%s.%s.%s`, %s, @end_timestamp)",
+		strings.ToUpper(c.conf.Mode), c.client.Project(), c.conf.DatasetID, c.conf.TableID, startExpr)
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying change history: %w", err)
+	}
+	it.PageInfo().MaxSize = c.conf.PageSize
+	c.end = end
+	c.log.Debugf("reading changes of %v until %v", c.conf.TableID, end)
+	return it, nil
+}
+
+// changeMetadata moves the change pseudo columns of a row into metadata.
+func changeMetadata(msg *service.Message) {
+	v, err := msg.AsStructuredMut()
+	if err != nil {
+		return
+	}
+	row, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	if t, ok := row["_CHANGE_TYPE"].(string); ok {
+		msg.MetaSetMut("bigquery_change_type", t)
+	}
+	if ts, ok := row["_CHANGE_TIMESTAMP"].(time.Time); ok {
+		msg.MetaSetMut("bigquery_change_timestamp", ts.UTC().Format(time.RFC3339Nano))
+	}
+	delete(row, "_CHANGE_TYPE")
+	delete(row, "_CHANGE_TIMESTAMP")
+}
@@ -0,0 +1,305 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/api/option"
+)
+
+const (
+	monitorViewJobs         = "jobs"
+	monitorViewWriteAPI     = "write_api"
+	monitorViewTableStorage = "table_storage"
+	monitorViewReservations = "reservations"
+	monitorViewAssignments  = "assignments"
+)
+
+// monitorQueries are the INFORMATION_SCHEMA queries of each view, formatted
+// with the region qualifier. Queries of windowed views aggregate over
+// [@window_start, @window_end).
+var monitorQueries = map[string]string{
+	monitorViewJobs: `SELECT
+  user_email,
+  job_type,
+  COUNT(*) AS jobs,
+  COUNTIF(error_result IS NOT NULL) AS failed_jobs,
+  IFNULL(SUM(total_bytes_billed), 0) AS total_bytes_billed,
+  IFNULL(SUM(total_slot_ms), 0) AS total_slot_ms
+FROM ` + "`%s`" + `.INFORMATION_SCHEMA.JOBS_BY_PROJECT
+WHERE state = 'DONE' AND end_time >= @window_start AND end_time < @window_end
+GROUP BY user_email, job_type`,
+	monitorViewWriteAPI: `SELECT
+  dataset_id,
+  table_id,
+  stream_type,
+  error_code,
+  SUM(total_requests) AS total_requests,
+  SUM(total_rows) AS total_rows,
+  SUM(total_input_bytes) AS total_input_bytes
+FROM ` + "`%s`" + `.INFORMATION_SCHEMA.WRITE_API_TIMELINE_BY_PROJECT
+WHERE start_timestamp >= @window_start AND start_timestamp < @window_end
+GROUP BY dataset_id, table_id, stream_type, error_code`,
+	monitorViewTableStorage: `SELECT
+  table_schema AS dataset_id,
+  table_name AS table_id,
+  total_rows,
+  total_logical_bytes,
+  active_logical_bytes,
+  long_term_logical_bytes,
+  total_physical_bytes
+FROM ` + "`%s`" + `.INFORMATION_SCHEMA.TABLE_STORAGE
+WHERE NOT deleted`,
+	monitorViewReservations: `WITH capacity AS (
+  SELECT
+    reservation_name,
+    MAX(slots_assigned) AS baseline_slots,
+    AVG(slots_assigned + IFNULL(autoscale.current_slots, 0)) AS slots_capacity
+  FROM ` + "`%[1]s`" + `.INFORMATION_SCHEMA.RESERVATIONS_TIMELINE
+  WHERE period_start >= @window_start AND period_start < @window_end
+  GROUP BY reservation_name
+), usage AS (
+  SELECT
+    ARRAY_REVERSE(SPLIT(reservation_id, '.'))[SAFE_OFFSET(0)] AS reservation_name,
+    SUM(period_slot_ms) / TIMESTAMP_DIFF(@window_end, @window_start, MILLISECOND) AS slots_used
+  FROM ` + "`%[1]s`" + `.INFORMATION_SCHEMA.JOBS_TIMELINE_BY_PROJECT
+  WHERE period_start >= @window_start AND period_start < @window_end AND reservation_id IS NOT NULL
+  GROUP BY reservation_name
+)
+SELECT
+  reservation_name,
+  IFNULL(baseline_slots, 0) AS baseline_slots,
+  IFNULL(slots_capacity, 0) AS slots_capacity,
+  IFNULL(slots_used, 0) AS slots_used,
+  SAFE_DIVIDE(slots_used, slots_capacity) AS utilization
+FROM capacity FULL OUTER JOIN usage USING (reservation_name)`,
+	monitorViewAssignments: `SELECT
+  reservation_name,
+  assignment_id,
+  job_type,
+  assignee_type,
+  assignee_id
+FROM ` + "`%s`" + `.INFORMATION_SCHEMA.ASSIGNMENTS`,
+}
+
+var monitorViews = []string{monitorViewJobs, monitorViewWriteAPI, monitorViewTableStorage, monitorViewReservations, monitorViewAssignments}
+
+// monitorSnapshotViews are the views that report the current state rather
+// than aggregate over the window of a poll.
+var monitorSnapshotViews = []string{monitorViewTableStorage, monitorViewAssignments}
+
+type gcpBigQueryMonitorInputConfig struct {
+	ProjectID       string
+	Region          string
+	CredentialsJSON string
+	Views           []string
+	Interval        time.Duration
+	Lag             time.Duration
+	CostPerTiB      float64
+}
+
+func gcpBigQueryMonitorInputConfigFromParsed(conf *service.ParsedConfig) (mconf gcpBigQueryMonitorInputConfig, err error) {
+	if mconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if mconf.ProjectID == "" {
+		mconf.ProjectID = bigquery.DetectProjectID
+	}
+	if mconf.Region, err = conf.FieldString("region"); err != nil {
+		return
+	}
+	if mconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if mconf.Views, err = conf.FieldStringList("views"); err != nil {
+		return
+	}
+	if mconf.Interval, err = conf.FieldDuration("interval"); err != nil {
+		return
+	}
+	if mconf.Lag, err = conf.FieldDuration("lag"); err != nil {
+		return
+	}
+	if mconf.CostPerTiB, err = conf.FieldFloat("cost_per_tib"); err != nil {
+		return
+	}
+	if len(mconf.Views) == 0 {
+		err = errors.New("at least one view must be monitored")
+		return
+	}
+	for _, v := range mconf.Views {
+		if !slices.Contains(monitorViews, v) {
+			err = fmt.Errorf("view %q is not one of %v", v, strings.Join(monitorViews, ", "))
+			return
+		}
+	}
+	if mconf.Interval <= 0 {
+		err = errors.New("interval must be greater than zero")
+		return
+	}
+	return
+}
+
+func gcpBigQueryMonitorConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services").
+		Summary(`Periodically queries BigQuery INFORMATION_SCHEMA views and emits their statistics as messages.`).
+		Description(`
+Every ` + "`interval`" + ` the configured views are queried and their statistics emitted as one batch of structured messages, each with the view it was read from in the ` + "`bigquery_monitor_view`" + ` metadata. Pipelines can then alert on write errors or runaway costs without a separate monitoring stack.
+
+- ` + "`jobs`" + `: the jobs completed since the previous poll, per user and job type, with the number of jobs and failed jobs, the bytes billed, the slot milliseconds used and the ` + "`estimated_cost`" + ` of the bytes billed at ` + "`cost_per_tib`" + `.
+- ` + "`write_api`" + `: the Storage Write API traffic since the previous poll, per table, stream type and error code, with the number of requests, rows and input bytes. Failed requests have a non-empty ` + "`error_code`" + `.
+- ` + "`table_storage`" + `: the current row count and logical and physical size of every table.
+- ` + "`reservations`" + `: the slot capacity of each reservation during the poll, its ` + "`baseline_slots`" + ` and average ` + "`slots_capacity`" + ` including autoscaled slots, along with the average ` + "`slots_used`" + ` by jobs of the project and the resulting ` + "`utilization`" + `. Reservations are read from the administration project they are created in.
+- ` + "`assignments`" + `: the current assignments of projects, folders and organizations to reservations.
+
+Every message carries the ` + "`window_start`" + ` and ` + "`window_end`" + ` of the poll. Windows follow each other without gaps and end ` + "`lag`" + ` behind the current time, as INFORMATION_SCHEMA views are updated with a small delay.`).
+		Field(service.NewStringField("project").Description("The project to monitor. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("region").Description("The region of the INFORMATION_SCHEMA views queried.").Example("eu").Default("us")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewStringListField("views").Description("The views to query, any of `jobs`, `write_api`, `table_storage`, `reservations` and `assignments`.").Default([]any{monitorViewJobs, monitorViewWriteAPI, monitorViewTableStorage})).
+		Field(service.NewDurationField("interval").Description("The time between polls.").Default("5m")).
+		Field(service.NewDurationField("lag").Description("How far behind the current time each window ends.").Default("1m")).
+		Field(service.NewFloatField("cost_per_tib").Description("The on-demand query price per TiB billed, used to estimate the cost of jobs.").Default(6.25))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_monitor", gcpBigQueryMonitorConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			mconf, err := gcpBigQueryMonitorInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(newGCPBigQueryMonitorInput(mconf, mgr)), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type gcpBigQueryMonitorInput struct {
+	conf gcpBigQueryMonitorInputConfig
+
+	client      *bigquery.Client
+	windowStart time.Time
+	nextPoll    time.Time
+
+	log *service.Logger
+}
+
+func newGCPBigQueryMonitorInput(conf gcpBigQueryMonitorInputConfig, mgr *service.Resources) *gcpBigQueryMonitorInput {
+	return &gcpBigQueryMonitorInput{
+		conf: conf,
+		log:  mgr.Logger(),
+	}
+}
+
+func (m *gcpBigQueryMonitorInput) Connect(ctx context.Context) error {
+	if m.client != nil {
+		return nil
+	}
+	var opts []option.ClientOption
+	if m.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(m.conf.CredentialsJSON)))
+	}
+	client, err := bigquery.NewClient(ctx, m.conf.ProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating big query client: %w", err)
+	}
+	m.client = client
+	return nil
+}
+
+func (m *gcpBigQueryMonitorInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if m.client == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+	for {
+		if wait := time.Until(m.nextPoll); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+		m.nextPoll = time.Now().Add(m.conf.Interval)
+
+		end := time.Now().Add(-m.conf.Lag)
+		start := m.windowStart
+		if start.IsZero() {
+			start = end.Add(-m.conf.Interval)
+		}
+
+		var batch service.MessageBatch
+		for _, view := range m.conf.Views {
+			msgs, err := m.queryView(ctx, view, start, end)
+			if err != nil {
+				return nil, nil, err
+			}
+			batch = append(batch, msgs...)
+		}
+		m.windowStart = end
+		if len(batch) > 0 {
+			return batch, func(context.Context, error) error { return nil }, nil
+		}
+	}
+}
+
+func (m *gcpBigQueryMonitorInput) Close(ctx context.Context) error {
+	if m.client != nil {
+		return m.client.Close()
+	}
+	return nil
+}
+
+// queryView queries the statistics of a view over the window [start, end).
+func (m *gcpBigQueryMonitorInput) queryView(ctx context.Context, view string, start, end time.Time) (service.MessageBatch, error) {
+	q := m.client.Query(fmt.Sprintf(monitorQueries[view], "region-"+m.conf.Region))
+	if !slices.Contains(monitorSnapshotViews, view) {
+		q.Parameters = []bigquery.QueryParameter{
+			{Name: "window_start", Value: start},
+			{Name: "window_end", Value: end},
+		}
+	}
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying %v statistics: %w", view, err)
+	}
+
+	var batch service.MessageBatch
+	for {
+		page, _, err := readQueryPage(it, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %v statistics: %w", view, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		batch = append(batch, page...)
+	}
+
+	for _, msg := range batch {
+		v, err := msg.AsStructuredMut()
+		if err != nil {
+			return nil, err
+		}
+		row := v.(map[string]any)
+		row["window_start"] = start
+		row["window_end"] = end
+		if view == monitorViewJobs {
+			billed, _ := row["total_bytes_billed"].(int64)
+			row["estimated_cost"] = float64(billed) / (1 << 40) * m.conf.CostPerTiB
+		}
+		msg.MetaSetMut("bigquery_monitor_view", view)
+	}
+	m.log.Debugf("read %d %v statistics", len(batch), view)
+	return batch, nil
+}
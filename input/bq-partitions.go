@@ -0,0 +1,382 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// partitionIDLayouts are the layouts of the IDs of time partitions by their
+// granularity.
+var partitionIDLayouts = map[bigquery.TimePartitioningType]string{
+	bigquery.HourPartitioningType:  "2006010215",
+	bigquery.DayPartitioningType:   "20060102",
+	bigquery.MonthPartitioningType: "200601",
+	bigquery.YearPartitioningType:  "2006",
+}
+
+type gcpBigQueryPartitionsInputConfig struct {
+	ProjectID       string
+	DatasetID       string
+	TableID         string
+	CredentialsJSON string
+	Start           string
+	End             string
+	PageSize        int
+}
+
+func gcpBigQueryPartitionsInputConfigFromParsed(conf *service.ParsedConfig) (pconf gcpBigQueryPartitionsInputConfig, err error) {
+	if pconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if pconf.ProjectID == "" {
+		pconf.ProjectID = bigquery.DetectProjectID
+	}
+	if pconf.DatasetID, err = conf.FieldString("dataset"); err != nil {
+		return
+	}
+	if pconf.TableID, err = conf.FieldString("table"); err != nil {
+		return
+	}
+	if pconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if pconf.Start, err = conf.FieldString("start"); err != nil {
+		return
+	}
+	if pconf.End, err = conf.FieldString("end"); err != nil {
+		return
+	}
+	if pconf.PageSize, err = conf.FieldInt("page_size"); err != nil {
+		return
+	}
+	if pconf.PageSize <= 0 {
+		err = errors.New("page_size must be greater than zero")
+		return
+	}
+	return
+}
+
+func gcpBigQueryPartitionsConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services").
+		Summary(`Reads a range of partitions of a partitioned BigQuery table, partition by partition.`).
+		Description(`
+The partitions of the table between ` + "`start`" + ` and ` + "`end`" + ` are listed from INFORMATION_SCHEMA.PARTITIONS and read in order, one query per partition, so that each query only scans a single partition. Rows are emitted as structured messages typed like those of the ` + "`gcp_bigquery_select`" + ` input, and the input ends once the last partition has been read. A partition whose query fails part way is read again from its start.
+
+Each message carries metadata describing the partition it was read from, so that downstream outputs can mirror the partitioning:
+
+| Metadata | Description |
+|----------|-------------|
+| ` + "`bigquery_partition_id`" + ` | The partition ID, e.g. ` + "`20230101`" + ` |
+| ` + "`bigquery_partition_type`" + ` | ` + "`HOUR`" + `, ` + "`DAY`" + `, ` + "`MONTH`" + `, ` + "`YEAR`" + ` or ` + "`RANGE`" + ` |
+| ` + "`bigquery_partition_field`" + ` | The partitioning column, empty for ingestion time partitioning |
+| ` + "`bigquery_partition_start`" + ` | The start of the partition, an RFC 3339 timestamp or an integer |
+
+Both ends of the range are inclusive and given as partition IDs, in which dashes, colons, spaces and the ` + "`T`" + ` of timestamps are ignored so that ` + "`2023-01-01`" + ` selects the partitions of that day at any granularity. For integer range partitioning they are the start values of partitions. The ` + "`__NULL__`" + ` and ` + "`__UNPARTITIONED__`" + ` partitions are never read.`).
+		Field(service.NewStringField("project").Description("The project ID of the table. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("dataset").Description("The BigQuery Dataset ID.")).
+		Field(service.NewStringField("table").Description("The partitioned table to read.")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewStringField("start").Description("The first partition to read. Leave empty to start at the first partition of the table.").Example("2023-01-01").Default("")).
+		Field(service.NewStringField("end").Description("The last partition to read. Leave empty to read up to the last partition of the table.").Example("2023-06-30").Default("")).
+		Field(service.NewIntField("page_size").Description("The maximum number of rows read per page, which is also the maximum size of the batches emitted.").Default(1000))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_partitions", gcpBigQueryPartitionsConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			pconf, err := gcpBigQueryPartitionsInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(newGCPBigQueryPartitionsInput(pconf, mgr)), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// tablePartitioning describes how a table is partitioned.
+type tablePartitioning struct {
+	kind      string
+	field     string
+	fieldType bigquery.FieldType
+	timeType  bigquery.TimePartitioningType
+	rng       *bigquery.RangePartitioningRange
+}
+
+type gcpBigQueryPartitionsInput struct {
+	conf gcpBigQueryPartitionsInputConfig
+
+	client       *bigquery.Client
+	partitioning tablePartitioning
+	partitions   []string
+	it           *bigquery.RowIterator
+	current      map[string]string
+
+	log *service.Logger
+}
+
+func newGCPBigQueryPartitionsInput(conf gcpBigQueryPartitionsInputConfig, mgr *service.Resources) *gcpBigQueryPartitionsInput {
+	return &gcpBigQueryPartitionsInput{
+		conf: conf,
+		log:  mgr.Logger(),
+	}
+}
+
+func (p *gcpBigQueryPartitionsInput) Connect(ctx context.Context) error {
+	if p.client != nil {
+		return nil
+	}
+	var opts []option.ClientOption
+	if p.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(p.conf.CredentialsJSON)))
+	}
+	client, err := bigquery.NewClient(ctx, p.conf.ProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating big query client: %w", err)
+	}
+
+	md, err := client.Dataset(p.conf.DatasetID).Table(p.conf.TableID).Metadata(ctx)
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("error reading metadata of table %v: %w", p.conf.TableID, err)
+	}
+	partitioning, err := partitioningOf(md)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	p.client = client
+	p.partitioning = partitioning
+
+	if p.partitions, err = p.listPartitions(ctx); err != nil {
+		p.client = nil
+		client.Close()
+		return err
+	}
+	p.log.Infof("reading %d partitions of table %v", len(p.partitions), p.conf.TableID)
+	return nil
+}
+
+func (p *gcpBigQueryPartitionsInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if p.client == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+	for {
+		if p.it == nil {
+			if len(p.partitions) == 0 {
+				return nil, nil, service.ErrEndOfInput
+			}
+			if err := p.readPartition(ctx, p.partitions[0]); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		batch, _, err := readQueryPage(p.it, p.conf.PageSize)
+		if err != nil {
+			// The iterator does not recover from errors, so the partition is
+			// read again from its start.
+			p.it = nil
+			return nil, nil, err
+		}
+		if len(batch) == 0 {
+			p.it = nil
+			p.partitions = p.partitions[1:]
+			continue
+		}
+		for _, msg := range batch {
+			for k, v := range p.current {
+				msg.MetaSetMut(k, v)
+			}
+		}
+		return batch, func(context.Context, error) error { return nil }, nil
+	}
+}
+
+func (p *gcpBigQueryPartitionsInput) Close(ctx context.Context) error {
+	if p.client != nil {
+		return p.client.Close()
+	}
+	return nil
+}
+
+// partitioningOf returns the partitioning of a table.
+func partitioningOf(md *bigquery.TableMetadata) (tablePartitioning, error) {
+	if rp := md.RangePartitioning; rp != nil && rp.Range != nil {
+		return tablePartitioning{kind: "RANGE", field: rp.Field, rng: rp.Range}, nil
+	}
+	tp := md.TimePartitioning
+	if tp == nil {
+		return tablePartitioning{}, fmt.Errorf("table %v is not partitioned", md.Name)
+	}
+	part := tablePartitioning{kind: string(tp.Type), field: tp.Field, timeType: tp.Type, fieldType: bigquery.TimestampFieldType}
+	if part.timeType == "" {
+		part.timeType = bigquery.DayPartitioningType
+		part.kind = string(part.timeType)
+	}
+	if _, ok := partitionIDLayouts[part.timeType]; !ok {
+		return tablePartitioning{}, fmt.Errorf("unsupported partitioning type %v", part.timeType)
+	}
+	if part.field != "" {
+		for _, f := range md.Schema {
+			if f.Name == part.field {
+				part.fieldType = f.Type
+			}
+		}
+	}
+	return part, nil
+}
+
+// partitionIDBound normalizes a range bound to a partition ID of the
+// partitioning, padding it with pad so that it sorts as the first or last
+// partition it covers.
+func (t tablePartitioning) partitionIDBound(bound string, pad byte) string {
+	id := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("-: T", r) {
+			return -1
+		}
+		return r
+	}, bound)
+	if t.kind == "RANGE" {
+		return id
+	}
+	n := len(partitionIDLayouts[t.timeType])
+	if len(id) > n {
+		return id[:n]
+	}
+	return id + strings.Repeat(string(pad), n-len(id))
+}
+
+func (p *gcpBigQueryPartitionsInput) listPartitions(ctx context.Context) ([]string, error) {
+	cond := []string{"table_name = @table", "partition_id NOT IN ('__NULL__', '__UNPARTITIONED__')"}
+	params := []bigquery.QueryParameter{{Name: "table", Value: p.conf.TableID}}
+	idExpr := "partition_id"
+	if p.partitioning.kind == "RANGE" {
+		idExpr = "SAFE_CAST(partition_id AS INT64)"
+	}
+	for _, b := range []struct {
+		name, value, op string
+		pad             byte
+	}{
+		{"start", p.conf.Start, ">=", '0'},
+		{"end", p.conf.End, "<=", '9'},
+	} {
+		if b.value == "" {
+			continue
+		}
+		id := p.partitioning.partitionIDBound(b.value, b.pad)
+		var v any = id
+		if p.partitioning.kind == "RANGE" {
+			n, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %v partition %q: %w", b.name, b.value, err)
+			}
+			v = n
+		}
+		cond = append(cond, fmt.Sprintf("%s %s @%s", idExpr, b.op, b.name))
+		params = append(params, bigquery.QueryParameter{Name: b.name, Value: v})
+	}
+
+	q := p.client.Query(fmt.Sprintf("SELECT partition_id FROM `%s.%s`.INFORMATION_SCHEMA.PARTITIONS WHERE %s ORDER BY %s",
+		p.client.Project(), p.conf.DatasetID, strings.Join(cond, " AND "), idExpr))
+	q.Parameters = params
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing partitions: %w", err)
+	}
+	var partitions []string
+	for {
+		var row []bigquery.Value
+		err := it.Next(&row)
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing partitions: %w", err)
+		}
+		if id, ok := row[0].(string); ok {
+			partitions = append(partitions, id)
+		}
+	}
+	return partitions, nil
+}
+
+// readPartition starts reading the rows of a partition.
+func (p *gcpBigQueryPartitionsInput) readPartition(ctx context.Context, id string) error {
+	part := p.partitioning
+	column := "`" + part.field + "`"
+	if part.field == "" {
+		column = "_PARTITIONTIME"
+	}
+
+	var lo, hi any
+	var start string
+	if part.kind == "RANGE" {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid partition %q: %w", id, err)
+		}
+		lo, hi, start = n, n+part.rng.Interval, id
+	} else {
+		t, err := time.Parse(partitionIDLayouts[part.timeType], id)
+		if err != nil {
+			return fmt.Errorf("invalid partition %q: %w", id, err)
+		}
+		end := partitionEnd(t, part.timeType)
+		start = t.Format(time.RFC3339)
+		switch part.fieldType {
+		case bigquery.DateFieldType:
+			lo, hi = civil.DateOf(t), civil.DateOf(end)
+		case bigquery.DateTimeFieldType:
+			lo, hi = civil.DateTimeOf(t), civil.DateTimeOf(end)
+		default:
+			lo, hi = t, end
+		}
+	}
+
+	sql := fmt.Sprintf("SELECT * FROM `%s.%s.%s` WHERE %s >= @lo AND %s < @hi",
+		p.client.Project(), p.conf.DatasetID, p.conf.TableID, column, column)
+	q := p.client.Query(sql)
+	q.Parameters = []bigquery.QueryParameter{{Name: "lo", Value: lo}, {Name: "hi", Value: hi}}
+	it, err := q.Read(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading partition %v: %w", id, err)
+	}
+	it.PageInfo().MaxSize = p.conf.PageSize
+	p.it = it
+	p.current = map[string]string{
+		"bigquery_partition_id":    id,
+		"bigquery_partition_type":  part.kind,
+		"bigquery_partition_field": part.field,
+		"bigquery_partition_start": start,
+	}
+	p.log.Infof("reading partition %v of table %v", id, p.conf.TableID)
+	return nil
+}
+
+// partitionEnd returns the start of the partition following the one starting
+// at t.
+func partitionEnd(t time.Time, typ bigquery.TimePartitioningType) time.Time {
+	switch typ {
+	case bigquery.HourPartitioningType:
+		return t.Add(time.Hour)
+	case bigquery.MonthPartitioningType:
+		return t.AddDate(0, 1, 0)
+	case bigquery.YearPartitioningType:
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
@@ -0,0 +1,338 @@
+package input
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/robfig/cron/v3"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+type gcpBigQuerySelectInputConfig struct {
+	ProjectID       string
+	CredentialsJSON string
+	Query           *service.InterpolatedString
+	ArgsMapping     *bloblang.Executor
+	PageSize        int
+	Loop            bool
+	Interval        time.Duration
+	Schedule        cron.Schedule
+
+	WatermarkColumn  string
+	WatermarkCache   string
+	WatermarkKey     string
+	WatermarkInitial string
+}
+
+func gcpBigQuerySelectInputConfigFromParsed(conf *service.ParsedConfig) (sconf gcpBigQuerySelectInputConfig, err error) {
+	if sconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if sconf.ProjectID == "" {
+		sconf.ProjectID = bigquery.DetectProjectID
+	}
+	if sconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if sconf.Query, err = conf.FieldInterpolatedString("query"); err != nil {
+		return
+	}
+	if conf.Contains("args_mapping") {
+		if sconf.ArgsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
+			return
+		}
+	}
+	if sconf.PageSize, err = conf.FieldInt("page_size"); err != nil {
+		return
+	}
+	if sconf.Loop, err = conf.FieldBool("loop"); err != nil {
+		return
+	}
+	if sconf.Interval, err = conf.FieldDuration("interval"); err != nil {
+		return
+	}
+	var schedule string
+	if schedule, err = conf.FieldString("schedule"); err != nil {
+		return
+	}
+	if schedule != "" {
+		parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		if sconf.Schedule, err = parser.Parse(schedule); err != nil {
+			err = fmt.Errorf("invalid schedule: %w", err)
+			return
+		}
+	}
+	if sconf.WatermarkColumn, err = conf.FieldString("watermark", "column"); err != nil {
+		return
+	}
+	if sconf.WatermarkCache, err = conf.FieldString("watermark", "cache"); err != nil {
+		return
+	}
+	if sconf.WatermarkKey, err = conf.FieldString("watermark", "key"); err != nil {
+		return
+	}
+	if sconf.WatermarkInitial, err = conf.FieldString("watermark", "initial"); err != nil {
+		return
+	}
+	if sconf.PageSize <= 0 {
+		err = errors.New("page_size must be greater than zero")
+		return
+	}
+	if sconf.WatermarkColumn != "" && (sconf.WatermarkCache == "" || sconf.WatermarkInitial == "") {
+		err = errors.New("watermark requires a cache and an initial value")
+		return
+	}
+	return
+}
+
+func gcpBigQuerySelectConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services").
+		Summary(`Executes a BigQuery SQL query and emits its result rows as messages.`).
+		Description(`
+The query is run when the input starts and its results are read page by page, each page being emitted as a batch with one structured message per row. Values are typed according to the result schema: records become objects, ` + "`NUMERIC`" + ` and ` + "`BIGNUMERIC`" + ` values decimal strings that keep their precision, and timestamps time values.
+
+Named query parameters are provided by ` + "`args_mapping`" + ` and referenced in the query as ` + "`@name`" + `. Interpolation functions in the query and the mapping are evaluated each time the query runs.
+
+By default the input ends once every row has been read. With ` + "`loop`" + ` the query runs again every ` + "`interval`" + `, measured from the start of the previous run. A run that fails before all of its rows have been read is run again.
+
+A cron ` + "`schedule`" + ` runs the query at fixed times instead, such as every hour or every day at midnight, so that recurring extract jobs can live entirely inside a config. The input then never ends, and ` + "`loop`" + ` and ` + "`interval`" + ` are ignored.
+
+### Incremental Queries
+
+With a ` + "`watermark`" + ` column the query is run incrementally: the value of that column in the last row delivered is stored in a cache resource and passed to each run of the query as the ` + "`@watermark`" + ` parameter, so that only newer rows are read. The query must filter on and be ordered by the column:
+
+` + "```sql" + `
+SELECT * FROM ` + "`my-project.my_dataset.orders`" + `
+WHERE updated_at > @watermark
+ORDER BY updated_at
+` + "```" + `
+
+The watermark is passed as a string, which BigQuery coerces when it is compared to a date or time column, other columns need an explicit ` + "`CAST`" + `. It only advances once a batch and all batches before it have been delivered, and survives restarts when the cache is persistent. Rows sharing the watermark value of the last row delivered are skipped by a ` + "`>`" + ` filter, use ` + "`>=`" + ` to read them again instead when the column is not unique.`).
+		Field(service.NewStringField("project").Description("The project that runs the query. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewInterpolatedStringField("query").Description("The SQL query to run, in GoogleSQL.").Example("SELECT id, name FROM `my-project.my_dataset.users` WHERE country = @country")).
+		Field(service.NewBloblangField("args_mapping").Description("An optional Bloblang mapping which should evaluate to an object of named query parameters.").Example(`root = { "country": "NL", "since": now().ts_sub_iso8601("P1D") }`).Optional()).
+		Field(service.NewIntField("page_size").Description("The maximum number of rows read per page, which is also the maximum size of the batches emitted.").Default(1000)).
+		Field(service.NewBoolField("loop").Description("Run the query again every `interval` rather than ending once its rows have been read.").Default(false)).
+		Field(service.NewDurationField("interval").Description("The time between the starts of consecutive runs when `loop` is enabled.").Default("1m")).
+		Field(service.NewStringField("schedule").Description("A cron expression to run the query on, with an optional seconds field. Descriptors such as `@hourly` and a `CRON_TZ=` time zone prefix are supported. Leave empty to run the query once or every `interval`.").Example("0 * * * *").Example("CRON_TZ=Europe/Amsterdam 0 2 * * *").Example("@daily").Default("")).
+		Field(service.NewObjectField("watermark",
+			service.NewStringField("column").Description("The column that rows are ordered by, leave empty to disable incremental queries.").Example("updated_at").Default(""),
+			service.NewStringField("cache").Description("The cache resource the watermark is stored in.").Default(""),
+			service.NewStringField("key").Description("The cache key of the watermark. Defaults to a key derived from the label of the input.").Default(""),
+			service.NewStringField("initial").Description("The watermark of the first run, used until a watermark has been stored.").Example("1970-01-01 00:00:00 UTC").Default(""),
+		).Description("Run the query incrementally, passing the value of the watermark column in the last row delivered as the `@watermark` parameter."))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_select", gcpBigQuerySelectConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			sconf, err := gcpBigQuerySelectInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(newGCPBigQuerySelectInput(sconf, mgr)), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type gcpBigQuerySelectInput struct {
+	conf gcpBigQuerySelectInputConfig
+
+	client  *bigquery.Client
+	it      *bigquery.RowIterator
+	ran     bool
+	nextRun time.Time
+	// retry is set when the last run failed before its results were read
+	// completely, so that it is run again right away.
+	retry bool
+
+	watermark *watermark
+
+	log *service.Logger
+}
+
+func newGCPBigQuerySelectInput(conf gcpBigQuerySelectInputConfig, mgr *service.Resources) *gcpBigQuerySelectInput {
+	s := &gcpBigQuerySelectInput{
+		conf: conf,
+		log:  mgr.Logger(),
+	}
+	if conf.WatermarkColumn != "" {
+		key := cmp.Or(conf.WatermarkKey, "gcp_bigquery_select/watermark/"+cmp.Or(mgr.Label(), conf.WatermarkColumn))
+		s.watermark = newWatermark(conf.WatermarkCache, key, conf.WatermarkInitial, mgr)
+	}
+	return s
+}
+
+func (s *gcpBigQuerySelectInput) Connect(ctx context.Context) error {
+	if s.client != nil {
+		return nil
+	}
+	var opts []option.ClientOption
+	if s.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(s.conf.CredentialsJSON)))
+	}
+	client, err := bigquery.NewClient(ctx, s.conf.ProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating big query client: %w", err)
+	}
+	s.client = client
+	return nil
+}
+
+func (s *gcpBigQuerySelectInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if s.client == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+	for {
+		if s.it == nil {
+			if s.ran && !s.conf.Loop && s.conf.Schedule == nil {
+				return nil, nil, service.ErrEndOfInput
+			}
+			if !s.retry {
+				if err := s.waitForRun(ctx); err != nil {
+					return nil, nil, err
+				}
+			}
+			it, err := s.runQuery(ctx)
+			if err != nil {
+				s.retry = true
+				return nil, nil, err
+			}
+			s.retry = false
+			s.it = it
+		}
+
+		batch, last, err := readQueryPage(s.it, s.conf.PageSize)
+		if err != nil {
+			// The iterator does not recover from errors, so the query is
+			// run again.
+			s.it = nil
+			s.retry = true
+			return nil, nil, err
+		}
+		if len(batch) == 0 {
+			s.it = nil
+			s.ran = true
+			continue
+		}
+		if s.watermark == nil {
+			return batch, func(context.Context, error) error { return nil }, nil
+		}
+		mark, err := columnWatermark(last, s.it.Schema, s.conf.WatermarkColumn)
+		if err != nil {
+			s.it = nil
+			return nil, nil, err
+		}
+		return batch, s.watermark.track(mark), nil
+	}
+}
+
+func (s *gcpBigQuerySelectInput) Close(ctx context.Context) error {
+	if s.client != nil {
+		return s.client.Close()
+	}
+	return nil
+}
+
+// waitForRun blocks until the next run of the query is due. With a schedule
+// the first run waits for the first scheduled time too.
+func (s *gcpBigQuerySelectInput) waitForRun(ctx context.Context) error {
+	if s.conf.Schedule != nil && s.nextRun.IsZero() {
+		s.nextRun = s.conf.Schedule.Next(time.Now())
+		s.log.Debugf("next query run at %v", s.nextRun)
+	}
+	wait := time.Until(s.nextRun)
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runQuery evaluates the query and its parameters and runs it, returning an
+// iterator over its result rows.
+func (s *gcpBigQuerySelectInput) runQuery(ctx context.Context) (*bigquery.RowIterator, error) {
+	if s.conf.Schedule != nil {
+		s.nextRun = s.conf.Schedule.Next(time.Now())
+	} else {
+		s.nextRun = time.Now().Add(s.conf.Interval)
+	}
+
+	sql, err := s.conf.Query.TryString(service.NewMessage(nil))
+	if err != nil {
+		return nil, fmt.Errorf("error interpolating query: %w", err)
+	}
+	q := s.client.Query(sql)
+	if s.conf.ArgsMapping != nil {
+		args, err := s.conf.ArgsMapping.Query(nil)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating args_mapping: %w", err)
+		}
+		obj, ok := args.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("args_mapping returned %T, expected an object", args)
+		}
+		for name, v := range obj {
+			q.Parameters = append(q.Parameters, bigquery.QueryParameter{Name: name, Value: v})
+		}
+	}
+	if s.watermark != nil {
+		// Rows of the previous run still in flight would be read again from
+		// a watermark that does not include them yet.
+		if err := s.watermark.wait(ctx); err != nil {
+			return nil, err
+		}
+		mark, err := s.watermark.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Name: "watermark", Value: mark})
+		s.log.Debugf("running query from watermark %v", mark)
+	}
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+	it.PageInfo().MaxSize = s.conf.PageSize
+	s.log.Debugf("query returned %d rows", it.TotalRows)
+	return it, nil
+}
+
+// readQueryPage reads up to size rows of a query result as structured
+// messages, returning an empty batch once all of them have been read. The
+// last row read is returned along with the batch.
+func readQueryPage(it *bigquery.RowIterator, size int) (batch service.MessageBatch, last map[string]bigquery.Value, err error) {
+	for len(batch) < size {
+		var row map[string]bigquery.Value
+		err := it.Next(&row)
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading query results: %w", err)
+		}
+		msg := service.NewMessage(nil)
+		msg.SetStructuredMut(structuredRow(row, it.Schema))
+		batch = append(batch, msg)
+		last = row
+	}
+	return batch, last, nil
+}
@@ -0,0 +1,143 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// streamDone is the checkpointed position of a stream that has been read to
+// its end.
+const streamDone = "done"
+
+// readCheckpoint is the state of a read session stored in the checkpoint
+// cache, so that a restarted input resumes the session from the positions of
+// its streams rather than reading the table again.
+type readCheckpoint struct {
+	Session    string            `json:"session"`
+	ExpireTime time.Time         `json:"expire_time"`
+	Format     string            `json:"format"`
+	Schema     []byte            `json:"schema"`
+	Streams    map[string]string `json:"streams"`
+}
+
+// readCheckpointer stores the positions of the streams of a session as rows
+// are delivered. Each stream is tracked by a watermark of its offset, so that
+// a position only advances once every row before it has been delivered.
+type readCheckpointer struct {
+	cache string
+	key   string
+
+	saveMu sync.Mutex
+	cp     readCheckpoint
+	marks  map[string]*watermark
+
+	mgr *service.Resources
+	log *service.Logger
+}
+
+func newReadCheckpointer(cache, key string, mgr *service.Resources) *readCheckpointer {
+	return &readCheckpointer{
+		cache: cache,
+		key:   key,
+		mgr:   mgr,
+		log:   mgr.Logger(),
+	}
+}
+
+// load returns the checkpoint of a session that can be resumed, or nil when
+// there is none. Sessions that expire within a minute are not resumed.
+func (c *readCheckpointer) load(ctx context.Context, format string) (*readCheckpoint, error) {
+	if c.cache == "" {
+		return nil, nil
+	}
+	var b []byte
+	var cacheErr error
+	if err := c.mgr.AccessCache(ctx, c.cache, func(cache service.Cache) {
+		if b, cacheErr = cache.Get(ctx, c.key); errors.Is(cacheErr, service.ErrKeyNotFound) {
+			cacheErr = nil
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("error accessing checkpoint cache: %w", err)
+	}
+	if cacheErr != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %w", cacheErr)
+	}
+	if b == nil {
+		return nil, nil
+	}
+
+	var cp readCheckpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		c.log.Warnf("ignoring unreadable checkpoint %v: %v", c.key, err)
+		return nil, nil
+	}
+	if cp.Format != format {
+		c.log.Warnf("ignoring checkpoint of session %v read as %v", cp.Session, cp.Format)
+		return nil, nil
+	}
+	if time.Until(cp.ExpireTime) < time.Minute {
+		c.log.Warnf("read session %v has expired, reading the table again", cp.Session)
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+// start begins tracking the streams of a session from the positions of cp,
+// storing it as the initial checkpoint.
+func (c *readCheckpointer) start(ctx context.Context, cp readCheckpoint) error {
+	c.cp = cp
+	c.marks = make(map[string]*watermark, len(cp.Streams))
+	for name, pos := range cp.Streams {
+		c.marks[name] = newWatermark("", "", pos, c.mgr)
+	}
+	return c.save(ctx)
+}
+
+// track registers a batch of a stream that advances its position to pos,
+// returning the function that acknowledges it. A nacked batch stays pending
+// and holds the position of its stream until the input redelivers it.
+func (c *readCheckpointer) track(stream, pos string) service.AckFunc {
+	ack := c.marks[stream].track(pos)
+	return func(ctx context.Context, err error) error {
+		if err := ack(ctx, err); err != nil || c.cache == "" {
+			return err
+		}
+		return c.save(ctx)
+	}
+}
+
+// save stores the delivered positions of all streams.
+func (c *readCheckpointer) save(ctx context.Context) error {
+	if c.cache == "" {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	cp := c.cp
+	cp.Streams = maps.Clone(cp.Streams)
+	for name, mark := range c.marks {
+		cp.Streams[name] = mark.current()
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	var cacheErr error
+	if err := c.mgr.AccessCache(ctx, c.cache, func(cache service.Cache) {
+		cacheErr = cache.Set(ctx, c.key, b, nil)
+	}); err != nil {
+		return fmt.Errorf("error accessing checkpoint cache: %w", err)
+	}
+	if cacheErr != nil {
+		return fmt.Errorf("error storing checkpoint: %w", cacheErr)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/linkedin/goavro/v2"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// rowDecoder decodes the rows of a read response into one structured message
+// per row.
+type rowDecoder func(resp *storagepb.ReadRowsResponse) (service.MessageBatch, error)
+
+// Layouts of the civil types of BigQuery, which have no time zone.
+const (
+	civilDateLayout     = "2006-01-02"
+	civilTimeLayout     = "15:04:05.999999"
+	civilDateTimeLayout = "2006-01-02T15:04:05.999999"
+)
+
+// newArrowDecoder returns a decoder of the Arrow record batches of a session.
+// BigQuery sends the schema once per session, so it is prepended to each
+// record batch to form a complete IPC stream.
+func newArrowDecoder(schema []byte) rowDecoder {
+	return func(resp *storagepb.ReadRowsResponse) (service.MessageBatch, error) {
+		serialized := resp.GetArrowRecordBatch().GetSerializedRecordBatch()
+		rdr, err := ipc.NewReader(bytes.NewReader(slices.Concat(schema, serialized)))
+		if err != nil {
+			return nil, err
+		}
+		defer rdr.Release()
+
+		var batch service.MessageBatch
+		for rdr.Next() {
+			rec := rdr.Record()
+			for i := 0; i < int(rec.NumRows()); i++ {
+				row := make(map[string]any, rec.NumCols())
+				for c, col := range rec.Columns() {
+					row[rec.ColumnName(c)] = arrowValue(col, i)
+				}
+				msg := service.NewMessage(nil)
+				msg.SetStructuredMut(row)
+				batch = append(batch, msg)
+			}
+		}
+		return batch, rdr.Err()
+	}
+}
+
+// arrowValue converts the value at index i of an Arrow column that BigQuery
+// encoded a column of its own type system as. NUMERIC and BIGNUMERIC values
+// become decimal strings that keep their precision, TIMESTAMP values time
+// values, civil dates and times their canonical string form and records
+// objects.
+func arrowValue(arr arrow.Array, i int) any {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch a := arr.(type) {
+	case *array.Decimal128:
+		return a.Value(i).ToString(a.DataType().(*arrow.Decimal128Type).Scale)
+	case *array.Decimal256:
+		return a.Value(i).ToString(a.DataType().(*arrow.Decimal256Type).Scale)
+	case *array.Timestamp:
+		dt := a.DataType().(*arrow.TimestampType)
+		t := a.Value(i).ToTime(dt.Unit)
+		if dt.TimeZone == "" {
+			// DATETIME columns are encoded without a time zone.
+			return t.Format(civilDateTimeLayout)
+		}
+		return t
+	case *array.Date32:
+		return a.Value(i).ToTime().Format(civilDateLayout)
+	case *array.Time64:
+		return a.Value(i).ToTime(a.DataType().(*arrow.Time64Type).Unit).Format(civilTimeLayout)
+	case *array.Struct:
+		dt := a.DataType().(*arrow.StructType)
+		obj := make(map[string]any, a.NumField())
+		for f := 0; f < a.NumField(); f++ {
+			obj[dt.Field(f).Name] = arrowValue(a.Field(f), i)
+		}
+		return obj
+	case *array.List:
+		start, end := a.ValueOffsets(i)
+		values := a.ListValues()
+		list := make([]any, 0, end-start)
+		for j := start; j < end; j++ {
+			list = append(list, arrowValue(values, int(j)))
+		}
+		return list
+	case *array.String:
+		return a.Value(i)
+	case *array.Binary:
+		return bytes.Clone(a.Value(i))
+	case *array.Int64:
+		return a.Value(i)
+	case *array.Float64:
+		return a.Value(i)
+	case *array.Boolean:
+		return a.Value(i)
+	}
+	return arr.GetOneForMarshal(i)
+}
+
+// newAvroDecoder returns a decoder of the Avro row blocks of a session, which
+// hold consecutive binary encoded records of the session schema.
+func newAvroDecoder(schema string) (rowDecoder, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing avro schema: %w", err)
+	}
+	var parsed any
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing avro schema: %w", err)
+	}
+	return func(resp *storagepb.ReadRowsResponse) (service.MessageBatch, error) {
+		buf := resp.GetAvroRows().GetSerializedBinaryRows()
+		var batch service.MessageBatch
+		for len(buf) > 0 {
+			var native any
+			var err error
+			if native, buf, err = codec.NativeFromBinary(buf); err != nil {
+				return nil, err
+			}
+			msg := service.NewMessage(nil)
+			msg.SetStructuredMut(avroValue(parsed, native))
+			batch = append(batch, msg)
+		}
+		return batch, nil
+	}, nil
+}
+
+// avroValue converts a value decoded by goavro according to its schema, with
+// the same typing as arrowValue. goavro wraps the values of unions, which
+// BigQuery only uses to make columns nullable, in a map keyed by the branch
+// type.
+func avroValue(schema, v any) any {
+	if v == nil {
+		return nil
+	}
+	switch s := schema.(type) {
+	case []any:
+		wrapped, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		for _, branch := range s {
+			if branch == "null" {
+				continue
+			}
+			for _, bv := range wrapped {
+				return avroValue(branch, bv)
+			}
+		}
+		return nil
+	case map[string]any:
+		switch s["type"] {
+		case "record":
+			rec, ok := v.(map[string]any)
+			if !ok {
+				return v
+			}
+			fields, _ := s["fields"].([]any)
+			obj := make(map[string]any, len(fields))
+			for _, f := range fields {
+				field, _ := f.(map[string]any)
+				name, _ := field["name"].(string)
+				obj[name] = avroValue(field["type"], rec[name])
+			}
+			return obj
+		case "array":
+			items, ok := v.([]any)
+			if !ok {
+				return v
+			}
+			list := make([]any, len(items))
+			for i, item := range items {
+				list[i] = avroValue(s["items"], item)
+			}
+			return list
+		}
+		return avroLogicalValue(s, v)
+	}
+	return v
+}
+
+// avroLogicalValue converts a value of an Avro logical type.
+func avroLogicalValue(schema map[string]any, v any) any {
+	switch t := v.(type) {
+	case *big.Rat:
+		scale, _ := schema["scale"].(float64)
+		return t.FloatString(int(scale))
+	case time.Time:
+		if schema["logicalType"] == "date" {
+			return t.UTC().Format(civilDateLayout)
+		}
+		return t.UTC()
+	case time.Duration:
+		return time.Time{}.Add(t).Format(civilTimeLayout)
+	}
+	return v
+}
@@ -0,0 +1,388 @@
+package input
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	storage "cloud.google.com/go/bigquery/storage/apiv1"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// storageReadRetryDelay is the pause before a read stream that failed with a
+// transient error is resumed from its offset.
+const storageReadRetryDelay = time.Second
+
+type gcpBigQueryStorageReadInputConfig struct {
+	ProjectID       string
+	DatasetID       string
+	TableID         string
+	CredentialsJSON string
+	MaxStreams      int
+	SelectedFields  []string
+	RowRestriction  string
+	SnapshotTime    time.Time
+	Format          string
+	CheckpointCache string
+	CheckpointKey   string
+}
+
+func gcpBigQueryStorageReadInputConfigFromParsed(conf *service.ParsedConfig) (rconf gcpBigQueryStorageReadInputConfig, err error) {
+	if rconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if rconf.ProjectID == "" {
+		rconf.ProjectID = bigquery.DetectProjectID
+	}
+	if rconf.DatasetID, err = conf.FieldString("dataset"); err != nil {
+		return
+	}
+	if rconf.TableID, err = conf.FieldString("table"); err != nil {
+		return
+	}
+	if rconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if rconf.MaxStreams, err = conf.FieldInt("max_streams"); err != nil {
+		return
+	}
+	if rconf.SelectedFields, err = conf.FieldStringList("selected_fields"); err != nil {
+		return
+	}
+	if rconf.RowRestriction, err = conf.FieldString("row_restriction"); err != nil {
+		return
+	}
+	if rconf.Format, err = conf.FieldString("format"); err != nil {
+		return
+	}
+	if rconf.CheckpointCache, err = conf.FieldString("checkpoint", "cache"); err != nil {
+		return
+	}
+	if rconf.CheckpointKey, err = conf.FieldString("checkpoint", "key"); err != nil {
+		return
+	}
+	var snapshot string
+	if snapshot, err = conf.FieldString("snapshot_time"); err != nil {
+		return
+	}
+	if snapshot != "" {
+		if rconf.SnapshotTime, err = time.Parse(time.RFC3339Nano, snapshot); err != nil {
+			err = fmt.Errorf("invalid snapshot_time: %w", err)
+			return
+		}
+	}
+	if rconf.MaxStreams < 0 {
+		err = errors.New("max_streams must not be negative")
+		return
+	}
+	return
+}
+
+func gcpBigQueryStorageReadConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services").
+		Summary(`Reads the rows of a BigQuery table using the Storage Read API.`).
+		Description(`
+A read session is created for the table when the input connects, and its streams are read in parallel. Each row is emitted as a structured message, and the input ends once every stream of the session has been read.
+
+Columns can be narrowed down with ` + "`selected_fields`" + ` and rows filtered with ` + "`row_restriction`" + `, both of which are applied by BigQuery so that only the requested data is transferred. Transient stream errors are retried from the last row read, and batches rejected downstream are redelivered until they are written, so that an export only ends once every row has been delivered.
+
+Rows are transferred in Arrow or Avro format and decoded preserving the types of BigQuery: ` + "`NUMERIC`" + ` and ` + "`BIGNUMERIC`" + ` values become decimal strings that keep their precision, ` + "`TIMESTAMP`" + ` values timestamps that serialize as RFC 3339, ` + "`DATE`" + `, ` + "`TIME`" + ` and ` + "`DATETIME`" + ` values their canonical string form and records objects.
+
+Rows are read from a consistent snapshot of the table taken when the read session is created, so rows streamed into the table while it is read are not included. Set ` + "`snapshot_time`" + ` to read the table as it was at an earlier point in time instead, like ` + "`FOR SYSTEM_TIME AS OF`" + ` does, which makes exports reproducible. The snapshot time must lie within the time travel window of the table.
+
+With ` + "`checkpoint.cache`" + ` set, the read session and the position of each of its streams are stored in a cache resource as rows are delivered, and a restarted input resumes the session where it left off instead of reading the table again. A session that has been read completely ends the input right away. Read sessions expire after six hours, after which the table is read again from the start. The read options of a resumed session are those it was created with, so the checkpoint must be deleted for changes to them to take effect.`).
+		Field(service.NewStringField("project").Description("The project ID of the table to read. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("dataset").Description("The BigQuery Dataset ID.")).
+		Field(service.NewStringField("table").Description("The table to read.")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewIntField("max_streams").Description("The maximum number of streams read in parallel. BigQuery may create fewer streams than requested, such as for small tables. Set to `0` to let BigQuery decide.").Default(1)).
+		Field(service.NewStringListField("selected_fields").Description("The columns to read, nested columns are selected with dotted paths. Leave empty to read every column.").Example([]string{"id", "payload.name"}).Default([]any{})).
+		Field(service.NewStringField("row_restriction").Description("A SQL filter over the columns of the table that rows must match to be read, without the `WHERE` keyword. Aggregates and subqueries are not supported.").Example(`country = "NL" AND created_at > "2024-01-01"`).Default("")).
+		Field(service.NewStringEnumField("format", "arrow", "avro").Description("The format rows are transferred in. Both decode to the same messages, Arrow is usually faster to read.").Default("arrow")).
+		Field(service.NewStringField("snapshot_time").Description("An RFC 3339 timestamp to read the table as of. Leave empty to read the table as of the creation of the read session.").Example("2024-01-01T00:00:00Z").Default("")).
+		Field(service.NewObjectField("checkpoint",
+			service.NewStringField("cache").Description("The cache resource the read session and its stream positions are stored in. Leave empty to read the table from the start on every run.").Default(""),
+			service.NewStringField("key").Description("The cache key of the checkpoint. Defaults to a key derived from the table.").Default(""),
+		).Description("Resume the read session of a previous run."))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_storage_read", gcpBigQueryStorageReadConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			rconf, err := gcpBigQueryStorageReadInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(newGCPBigQueryStorageReadInput(rconf, mgr)), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type gcpBigQueryStorageReadInput struct {
+	conf gcpBigQueryStorageReadInputConfig
+
+	client     *storage.BigQueryReadClient
+	checkpoint *readCheckpointer
+	batches    chan readBatch
+	errs       chan error
+	wg         sync.WaitGroup
+
+	ctx      context.Context
+	shutdown context.CancelFunc
+
+	mgr *service.Resources
+	log *service.Logger
+}
+
+// readBatch is a batch of rows read from a stream, along with the function
+// that checkpoints the stream once the batch is delivered.
+type readBatch struct {
+	batch service.MessageBatch
+	ack   service.AckFunc
+}
+
+func newGCPBigQueryStorageReadInput(conf gcpBigQueryStorageReadInputConfig, mgr *service.Resources) *gcpBigQueryStorageReadInput {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &gcpBigQueryStorageReadInput{
+		conf:     conf,
+		batches:  make(chan readBatch),
+		errs:     make(chan error, 1),
+		ctx:      ctx,
+		shutdown: cancel,
+		mgr:      mgr,
+		log:      mgr.Logger(),
+	}
+}
+
+func (r *gcpBigQueryStorageReadInput) Connect(ctx context.Context) error {
+	if r.client != nil {
+		return nil
+	}
+
+	var opts []option.ClientOption
+	if r.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(r.conf.CredentialsJSON)))
+	}
+	project := r.conf.ProjectID
+	if project == bigquery.DetectProjectID {
+		bqClient, err := bigquery.NewClient(ctx, project, opts...)
+		if err != nil {
+			return fmt.Errorf("error creating big query client: %w", err)
+		}
+		project = bqClient.Project()
+		bqClient.Close()
+	}
+
+	client, err := storage.NewBigQueryReadClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating BigQuery read client: %w", err)
+	}
+	key := cmp.Or(r.conf.CheckpointKey, fmt.Sprintf("gcp_bigquery_storage_read/%s/%s/%s", project, r.conf.DatasetID, r.conf.TableID))
+	checkpoint := newReadCheckpointer(r.conf.CheckpointCache, key, r.mgr)
+	cp, err := checkpoint.load(ctx, r.conf.Format)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	if cp != nil {
+		r.log.Infof("resuming read session %v", cp.Session)
+	} else if cp, err = r.createSession(ctx, client, project); err != nil {
+		client.Close()
+		return err
+	}
+
+	decode := newArrowDecoder(cp.Schema)
+	if cp.Format == "avro" {
+		if decode, err = newAvroDecoder(string(cp.Schema)); err != nil {
+			client.Close()
+			return err
+		}
+	}
+	if err := checkpoint.start(ctx, *cp); err != nil {
+		client.Close()
+		return err
+	}
+	r.client = client
+	r.checkpoint = checkpoint
+
+	var streams int
+	for name, pos := range cp.Streams {
+		if pos == streamDone {
+			continue
+		}
+		offset, err := strconv.ParseInt(pos, 10, 64)
+		if err != nil {
+			r.log.Warnf("reading stream %v from the start, invalid checkpoint %q", name, pos)
+		}
+		streams++
+		r.wg.Add(1)
+		go r.readStream(name, decode, offset)
+	}
+	go func() {
+		r.wg.Wait()
+		close(r.batches)
+	}()
+
+	if r.conf.SnapshotTime.IsZero() {
+		r.log.Infof("reading %s.%s.%s with %d streams", project, r.conf.DatasetID, r.conf.TableID, streams)
+	} else {
+		r.log.Infof("reading %s.%s.%s as of %v with %d streams", project, r.conf.DatasetID, r.conf.TableID, r.conf.SnapshotTime.Format(time.RFC3339Nano), streams)
+	}
+	return nil
+}
+
+func (r *gcpBigQueryStorageReadInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	select {
+	case rb, ok := <-r.batches:
+		if !ok {
+			return nil, nil, service.ErrEndOfInput
+		}
+		return rb.batch, rb.ack, nil
+	case err := <-r.errs:
+		return nil, nil, err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (r *gcpBigQueryStorageReadInput) Close(ctx context.Context) error {
+	r.shutdown()
+	r.wg.Wait()
+	if r.client != nil {
+		return r.client.Close()
+	}
+	return nil
+}
+
+// readStream reads a stream of the session to its end from offset, resuming
+// from the last row read after transient errors.
+func (r *gcpBigQueryStorageReadInput) readStream(name string, decode rowDecoder, offset int64) {
+	defer r.wg.Done()
+	for {
+		err := r.readRows(name, decode, &offset)
+		if err == nil {
+			// The stream is done once the batches before have been delivered.
+			if err := r.checkpoint.track(name, streamDone)(r.ctx, nil); err != nil {
+				r.log.Warnf("unable to checkpoint stream %v: %v", name, err)
+			}
+			return
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+		if !isTransientReadError(err) {
+			select {
+			case r.errs <- fmt.Errorf("error reading stream %v: %w", name, err):
+			case <-r.ctx.Done():
+			}
+			return
+		}
+		r.log.Warnf("reading stream %v failed at row %d, resuming: %v", name, offset, err)
+		select {
+		case <-time.After(storageReadRetryDelay):
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *gcpBigQueryStorageReadInput) readRows(name string, decode rowDecoder, offset *int64) error {
+	rows, err := r.client.ReadRows(r.ctx, &storagepb.ReadRowsRequest{ReadStream: name, Offset: *offset})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := rows.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		batch, err := decode(resp)
+		if err != nil {
+			return fmt.Errorf("error decoding rows: %w", err)
+		}
+		*offset += resp.GetRowCount()
+		rb := readBatch{batch: batch, ack: r.checkpoint.track(name, strconv.FormatInt(*offset, 10))}
+		select {
+		case r.batches <- rb:
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+	}
+}
+
+// isTransientReadError reports whether reading a stream may succeed when
+// resumed.
+func isTransientReadError(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.Internal, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// createSession creates a read session of the table, returning it as the
+// initial checkpoint with every stream at its start.
+func (r *gcpBigQueryStorageReadInput) createSession(ctx context.Context, client *storage.BigQueryReadClient, project string) (*readCheckpoint, error) {
+	readSession := &storagepb.ReadSession{
+		Table:      fmt.Sprintf("projects/%s/datasets/%s/tables/%s", project, r.conf.DatasetID, r.conf.TableID),
+		DataFormat: storagepb.DataFormat_ARROW,
+		ReadOptions: &storagepb.ReadSession_TableReadOptions{
+			SelectedFields: r.conf.SelectedFields,
+			RowRestriction: r.conf.RowRestriction,
+		},
+	}
+	if r.conf.Format == "avro" {
+		readSession.DataFormat = storagepb.DataFormat_AVRO
+	}
+	if !r.conf.SnapshotTime.IsZero() {
+		readSession.TableModifiers = &storagepb.ReadSession_TableModifiers{
+			SnapshotTime: timestamppb.New(r.conf.SnapshotTime),
+		}
+	}
+	session, err := client.CreateReadSession(ctx, &storagepb.CreateReadSessionRequest{
+		Parent:         "projects/" + project,
+		ReadSession:    readSession,
+		MaxStreamCount: int32(r.conf.MaxStreams),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating read session: %w", err)
+	}
+
+	cp := &readCheckpoint{
+		Session:    session.GetName(),
+		ExpireTime: session.GetExpireTime().AsTime(),
+		Format:     r.conf.Format,
+		Schema:     session.GetArrowSchema().GetSerializedSchema(),
+		Streams:    make(map[string]string, len(session.GetStreams())),
+	}
+	if r.conf.Format == "avro" {
+		cp.Schema = []byte(session.GetAvroSchema().GetSchema())
+	}
+	for _, s := range session.GetStreams() {
+		cp.Streams[s.GetName()] = "0"
+	}
+	return cp, nil
+}
@@ -0,0 +1,359 @@
+package input
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/api/option"
+)
+
+type gcpBigQueryBenchInputConfig struct {
+	ProjectID       string
+	DatasetID       string
+	TableID         string
+	CredentialsJSON string
+	Rate            int
+	BatchSize       int
+	Count           int
+	ReportInterval  time.Duration
+}
+
+func gcpBigQueryBenchInputConfigFromParsed(conf *service.ParsedConfig) (bconf gcpBigQueryBenchInputConfig, err error) {
+	if bconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if bconf.ProjectID == "" {
+		bconf.ProjectID = bigquery.DetectProjectID
+	}
+	if bconf.DatasetID, err = conf.FieldString("dataset"); err != nil {
+		return
+	}
+	if bconf.TableID, err = conf.FieldString("table"); err != nil {
+		return
+	}
+	if bconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if bconf.Rate, err = conf.FieldInt("rate"); err != nil {
+		return
+	}
+	if bconf.BatchSize, err = conf.FieldInt("batch_size"); err != nil {
+		return
+	}
+	if bconf.Count, err = conf.FieldInt("count"); err != nil {
+		return
+	}
+	if bconf.ReportInterval, err = conf.FieldDuration("report_interval"); err != nil {
+		return
+	}
+	if bconf.Rate <= 0 || bconf.BatchSize <= 0 {
+		err = fmt.Errorf("rate and batch_size must be greater than zero")
+		return
+	}
+	return
+}
+
+func gcpBigQueryBenchConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services", "Utility").
+		Summary(`Generates synthetic rows matching the schema of a BigQuery table at a target rate, for load testing the ` + "`gcp_bigquery_stream`" + ` output.`).
+		Description(`
+Rows are generated from the destination table schema in the JSON form accepted by the ` + "`gcp_bigquery_stream`" + ` output. Pair this input with that output pointing at the same table to measure achievable append throughput without a separate load generation harness.
+
+The input tracks acknowledgements from the output and periodically logs the achieved row rate, acknowledgement latency percentiles and error rate, with a final report once ` + "`count`" + ` rows have been acknowledged.`).
+		Field(service.NewStringField("project").Description("The project ID of the table to generate rows for. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("dataset").Description("The BigQuery Dataset ID.")).
+		Field(service.NewStringField("table").Description("The table whose schema rows are generated for.")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewIntField("rate").Description("The target number of rows generated per second.").Default(1000)).
+		Field(service.NewIntField("batch_size").Description("The number of rows emitted per batch.").Default(100)).
+		Field(service.NewIntField("count").Description("The total number of rows to generate before the input ends. Set to `0` to generate indefinitely.").Default(0)).
+		Field(service.NewDurationField("report_interval").Description("How often throughput, latency and error statistics are logged.").Default("10s"))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_stream_bench", gcpBigQueryBenchConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			bconf, err := gcpBigQueryBenchInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return newGCPBigQueryBenchInput(bconf, mgr), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type gcpBigQueryBenchInput struct {
+	conf gcpBigQueryBenchInputConfig
+
+	schema    bigquery.Schema
+	rnd       *rand.Rand
+	generated int
+	next      time.Time
+
+	stats *benchStats
+	done  chan struct{}
+
+	log *service.Logger
+}
+
+func newGCPBigQueryBenchInput(conf gcpBigQueryBenchInputConfig, mgr *service.Resources) *gcpBigQueryBenchInput {
+	return &gcpBigQueryBenchInput{
+		conf:  conf,
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		stats: &benchStats{},
+		done:  make(chan struct{}),
+		log:   mgr.Logger(),
+	}
+}
+
+func (b *gcpBigQueryBenchInput) Connect(ctx context.Context) error {
+	var opts []option.ClientOption
+	if b.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(b.conf.CredentialsJSON)))
+	}
+	client, err := bigquery.NewClient(ctx, b.conf.ProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating big query client: %w", err)
+	}
+	defer client.Close()
+
+	metadata, err := client.DatasetInProject(client.Project(), b.conf.DatasetID).Table(b.conf.TableID).Metadata(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading table metadata: %w", err)
+	}
+	b.schema = metadata.Schema
+	if b.stats.start.IsZero() {
+		// Reconnects carry on with the same statistics and pacing.
+		b.stats.start = time.Now()
+		b.next = b.stats.start
+		go b.report()
+	}
+	b.log.Infof("generating synthetic rows for %s.%s.%s at %d rows/s", client.Project(), b.conf.DatasetID, b.conf.TableID, b.conf.Rate)
+	return nil
+}
+
+func (b *gcpBigQueryBenchInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	size := b.conf.BatchSize
+	if b.conf.Count > 0 {
+		if b.generated >= b.conf.Count {
+			return nil, nil, service.ErrEndOfInput
+		}
+		size = min(size, b.conf.Count-b.generated)
+	}
+
+	// Pace batches so that the generated row rate matches the target.
+	if wait := time.Until(b.next); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+	b.next = b.next.Add(time.Duration(size) * time.Second / time.Duration(b.conf.Rate))
+
+	batch := make(service.MessageBatch, 0, size)
+	for i := 0; i < size; i++ {
+		row, err := json.Marshal(b.generateRow(b.schema))
+		if err != nil {
+			return nil, nil, err
+		}
+		batch = append(batch, service.NewMessage(row))
+	}
+	b.generated += size
+
+	sent := time.Now()
+	return batch, func(ctx context.Context, err error) error {
+		b.stats.record(size, time.Since(sent), err)
+		return nil
+	}, nil
+}
+
+func (b *gcpBigQueryBenchInput) Close(ctx context.Context) error {
+	select {
+	case <-b.done:
+	default:
+		close(b.done)
+	}
+	if !b.stats.start.IsZero() {
+		b.log.Infof("bench final report: %s", b.stats.summary(true))
+	}
+	return nil
+}
+
+func (b *gcpBigQueryBenchInput) report() {
+	t := time.NewTicker(b.conf.ReportInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			b.log.Infof("bench report: %s", b.stats.summary(false))
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// generateRow produces a random row for the schema in the JSON form accepted
+// by the gcp_bigquery_stream output.
+func (b *gcpBigQueryBenchInput) generateRow(schema bigquery.Schema) map[string]any {
+	row := make(map[string]any, len(schema))
+	for _, f := range schema {
+		if f.Type == bigquery.RangeFieldType {
+			continue
+		}
+		if f.Repeated {
+			n := 1 + b.rnd.Intn(3)
+			values := make([]any, n)
+			for i := range values {
+				values[i] = b.generateValue(f)
+			}
+			row[f.Name] = values
+			continue
+		}
+		row[f.Name] = b.generateValue(f)
+	}
+	return row
+}
+
+func (b *gcpBigQueryBenchInput) generateValue(f *bigquery.FieldSchema) any {
+	now := time.Now().UTC()
+	switch f.Type {
+	case bigquery.RecordFieldType:
+		return b.generateRow(f.Schema)
+	case bigquery.IntegerFieldType:
+		return b.rnd.Int63n(1_000_000)
+	case bigquery.FloatFieldType:
+		return b.rnd.Float64() * 1000
+	case bigquery.BooleanFieldType:
+		return b.rnd.Intn(2) == 1
+	case bigquery.BytesFieldType:
+		buf := make([]byte, 16)
+		b.rnd.Read(buf)
+		return base64.StdEncoding.EncodeToString(buf)
+	case bigquery.TimestampFieldType:
+		return now.UnixMicro()
+	case bigquery.DateFieldType:
+		return now.Unix() / 86400
+	case bigquery.TimeFieldType:
+		return packCivilTime(now)
+	case bigquery.DateTimeFieldType:
+		return int64(now.Year())<<46 | int64(now.Month())<<42 | int64(now.Day())<<37 | packCivilTime(now)
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return encodeScaledInt(b.rnd.Int63n(1_000_000), f.Type == bigquery.BigNumericFieldType)
+	case bigquery.GeographyFieldType:
+		return fmt.Sprintf("POINT(%f %f)", b.rnd.Float64()*360-180, b.rnd.Float64()*180-90)
+	case bigquery.JSONFieldType:
+		return fmt.Sprintf(`{"value":%d}`, b.rnd.Intn(1000))
+	}
+	return randomString(b.rnd, 8+b.rnd.Intn(9))
+}
+
+// packCivilTime encodes the time of day in the packed int64 form used by the
+// Write API for TIME columns and the lower bits of DATETIME columns.
+func packCivilTime(t time.Time) int64 {
+	return int64(t.Hour())<<32 | int64(t.Minute())<<26 | int64(t.Second())<<20 | int64(t.Nanosecond()/1000)
+}
+
+// encodeScaledInt encodes a whole number as the base64 little-endian two's
+// complement bytes of NUMERIC (scale 9) or BIGNUMERIC (scale 38) values.
+func encodeScaledInt(n int64, big bool) string {
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(n*1_000_000_000))
+	if big {
+		// Scale the remaining 29 digits by repeated multiplication.
+		for i := 0; i < bigNumericScale-numericScale; i++ {
+			buf = mulSmall(buf, 10)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(append(buf, 0))
+}
+
+// mulSmall multiplies a little-endian unsigned integer by m.
+func mulSmall(v []byte, m uint16) []byte {
+	var carry uint16
+	for i := range v {
+		x := uint16(v[i])*m + carry
+		v[i] = byte(x)
+		carry = x >> 8
+	}
+	for carry > 0 {
+		v = append(v, byte(carry))
+		carry >>= 8
+	}
+	return v
+}
+
+const (
+	numericScale    = 9
+	bigNumericScale = 38
+)
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(rnd *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rnd.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// benchStats aggregates acknowledgement outcomes reported by the pipeline.
+type benchStats struct {
+	mu        sync.Mutex
+	start     time.Time
+	rows      int
+	errRows   int
+	latencies []time.Duration
+}
+
+func (s *benchStats) record(rows int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows += rows
+	if err != nil {
+		s.errRows += rows
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+// summary formats the statistics accumulated since the start. Unless total is
+// true, old latency samples are dropped afterwards to bound memory.
+func (s *benchStats) summary(total bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.start).Seconds()
+	lat := slices.Clone(s.latencies)
+	slices.Sort(lat)
+	pct := func(p float64) time.Duration {
+		if len(lat) == 0 {
+			return 0
+		}
+		return lat[int(p*float64(len(lat)-1))]
+	}
+	errRate := 0.0
+	if s.rows > 0 {
+		errRate = float64(s.errRows) / float64(s.rows)
+	}
+	out := fmt.Sprintf("acked_rows=%d rows_per_sec=%.1f error_rate=%.4f p50=%v p90=%v p99=%v",
+		s.rows, float64(s.rows)/elapsed, errRate, pct(0.5), pct(0.9), pct(0.99))
+	if !total && len(s.latencies) > 100_000 {
+		// Bound memory for long running benchmarks by keeping a sample.
+		s.latencies = s.latencies[len(s.latencies)-10_000:]
+	}
+	return out
+}
@@ -0,0 +1,296 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+type gcpBigQueryTablesInputConfig struct {
+	ProjectID       string
+	CredentialsJSON string
+	Datasets        []string
+	Tables          []string
+	Loop            bool
+	Interval        time.Duration
+}
+
+func gcpBigQueryTablesInputConfigFromParsed(conf *service.ParsedConfig) (tconf gcpBigQueryTablesInputConfig, err error) {
+	if tconf.ProjectID, err = conf.FieldString("project"); err != nil {
+		return
+	}
+	if tconf.ProjectID == "" {
+		tconf.ProjectID = bigquery.DetectProjectID
+	}
+	if tconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+		return
+	}
+	if tconf.Datasets, err = conf.FieldStringList("datasets"); err != nil {
+		return
+	}
+	if tconf.Tables, err = conf.FieldStringList("tables"); err != nil {
+		return
+	}
+	if tconf.Loop, err = conf.FieldBool("loop"); err != nil {
+		return
+	}
+	if tconf.Interval, err = conf.FieldDuration("interval"); err != nil {
+		return
+	}
+	for _, p := range slices.Concat(tconf.Datasets, tconf.Tables) {
+		if _, err = path.Match(p, ""); err != nil {
+			err = fmt.Errorf("invalid pattern %q: %w", p, err)
+			return
+		}
+	}
+	return
+}
+
+func gcpBigQueryTablesConfig() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("GCP", "Services").
+		Summary(`Lists the BigQuery tables matching a pattern and emits their metadata as messages.`).
+		Description(`
+The datasets of the project matching any of ` + "`datasets`" + ` are listed, and a message is emitted for each of their tables matching any of ` + "`tables`" + `, batched per dataset. Patterns are shell globs such as ` + "`events_*`" + `.
+
+Each message is a structured object with the ` + "`project`" + `, ` + "`dataset`" + `, ` + "`table`" + `, ` + "`type`" + `, ` + "`description`" + ` and ` + "`labels`" + ` of the table, its ` + "`schema`" + ` in the JSON format of the bq tool, its ` + "`time_partitioning`" + `, ` + "`range_partitioning`" + ` and ` + "`clustering`" + ` when set, its size as ` + "`num_rows`" + ` and ` + "`num_bytes`" + `, and its ` + "`creation_time`" + ` and ` + "`last_modified_time`" + `. The ` + "`bigquery_dataset`" + ` and ` + "`bigquery_table`" + ` metadata are set as well.
+
+By default the input ends once every matching table has been emitted. With ` + "`loop`" + ` the tables are listed again every ` + "`interval`" + `, so that downstream automation picks up new tables.`).
+		Field(service.NewStringField("project").Description("The project to list the tables of. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewStringListField("datasets").Description("Patterns of the datasets to list.").Example([]string{"analytics_*"}).Default([]any{"*"})).
+		Field(service.NewStringListField("tables").Description("Patterns of the tables to emit.").Example([]string{"events_*", "users"}).Default([]any{"*"})).
+		Field(service.NewBoolField("loop").Description("List the tables again every `interval` rather than ending once they have been emitted.").Default(false)).
+		Field(service.NewDurationField("interval").Description("The time between the starts of consecutive listings when `loop` is enabled.").Default("1h"))
+}
+
+func init() {
+	err := service.RegisterBatchInput(
+		"gcp_bigquery_tables", gcpBigQueryTablesConfig(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			tconf, err := gcpBigQueryTablesInputConfigFromParsed(conf)
+			if err != nil {
+				return nil, err
+			}
+			return service.AutoRetryNacksBatched(newGCPBigQueryTablesInput(tconf, mgr)), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type gcpBigQueryTablesInput struct {
+	conf gcpBigQueryTablesInputConfig
+
+	client   *bigquery.Client
+	datasets []string
+	listed   bool
+	nextList time.Time
+
+	log *service.Logger
+}
+
+func newGCPBigQueryTablesInput(conf gcpBigQueryTablesInputConfig, mgr *service.Resources) *gcpBigQueryTablesInput {
+	return &gcpBigQueryTablesInput{
+		conf: conf,
+		log:  mgr.Logger(),
+	}
+}
+
+func (t *gcpBigQueryTablesInput) Connect(ctx context.Context) error {
+	if t.client != nil {
+		return nil
+	}
+	var opts []option.ClientOption
+	if t.conf.CredentialsJSON != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(t.conf.CredentialsJSON)))
+	}
+	client, err := bigquery.NewClient(ctx, t.conf.ProjectID, opts...)
+	if err != nil {
+		return fmt.Errorf("error creating big query client: %w", err)
+	}
+	t.client = client
+	return nil
+}
+
+func (t *gcpBigQueryTablesInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if t.client == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+	for {
+		if len(t.datasets) == 0 {
+			if t.listed && !t.conf.Loop {
+				return nil, nil, service.ErrEndOfInput
+			}
+			if wait := time.Until(t.nextList); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return nil, nil, ctx.Err()
+				}
+			}
+			t.listed = true
+			t.nextList = time.Now().Add(t.conf.Interval)
+			datasets, err := t.listDatasets(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(datasets) == 0 {
+				continue
+			}
+			t.datasets = datasets
+		}
+
+		batch, err := t.readDataset(ctx, t.datasets[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		t.datasets = t.datasets[1:]
+		if len(batch) > 0 {
+			return batch, func(context.Context, error) error { return nil }, nil
+		}
+	}
+}
+
+func (t *gcpBigQueryTablesInput) Close(ctx context.Context) error {
+	if t.client != nil {
+		return t.client.Close()
+	}
+	return nil
+}
+
+// matchAny reports whether name matches any of the patterns.
+func matchAny(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool {
+		ok, _ := path.Match(p, name)
+		return ok
+	})
+}
+
+func (t *gcpBigQueryTablesInput) listDatasets(ctx context.Context) ([]string, error) {
+	var datasets []string
+	it := t.client.Datasets(ctx)
+	for {
+		ds, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing datasets: %w", err)
+		}
+		if matchAny(t.conf.Datasets, ds.DatasetID) {
+			datasets = append(datasets, ds.DatasetID)
+		}
+	}
+	t.log.Debugf("listed %d matching datasets", len(datasets))
+	return datasets, nil
+}
+
+// readDataset emits the metadata of the matching tables of a dataset. Tables
+// deleted while they are listed are skipped.
+func (t *gcpBigQueryTablesInput) readDataset(ctx context.Context, dataset string) (service.MessageBatch, error) {
+	var batch service.MessageBatch
+	it := t.client.Dataset(dataset).Tables(ctx)
+	for {
+		table, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing tables of dataset %v: %w", dataset, err)
+		}
+		if !matchAny(t.conf.Tables, table.TableID) {
+			continue
+		}
+		md, err := table.Metadata(ctx)
+		if err != nil {
+			if hasStatusCode(err, http.StatusNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("error reading metadata of table %v.%v: %w", dataset, table.TableID, err)
+		}
+		v, err := tableMetadataValue(table, md)
+		if err != nil {
+			return nil, err
+		}
+		msg := service.NewMessage(nil)
+		msg.SetStructuredMut(v)
+		msg.MetaSetMut("bigquery_dataset", dataset)
+		msg.MetaSetMut("bigquery_table", table.TableID)
+		batch = append(batch, msg)
+	}
+	return batch, nil
+}
+
+// tableMetadataValue converts the metadata of a table into a structured
+// message value.
+func tableMetadataValue(table *bigquery.Table, md *bigquery.TableMetadata) (map[string]any, error) {
+	b, err := md.Schema.ToJSONFields()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding schema of table %v: %w", table.TableID, err)
+	}
+	var schema any
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]any, len(md.Labels))
+	for k, v := range md.Labels {
+		labels[k] = v
+	}
+	v := map[string]any{
+		"project":            table.ProjectID,
+		"dataset":            table.DatasetID,
+		"table":              table.TableID,
+		"type":               string(md.Type),
+		"description":        md.Description,
+		"labels":             labels,
+		"schema":             schema,
+		"num_rows":           int64(md.NumRows),
+		"num_bytes":          md.NumBytes,
+		"creation_time":      md.CreationTime,
+		"last_modified_time": md.LastModifiedTime,
+	}
+	if tp := md.TimePartitioning; tp != nil {
+		v["time_partitioning"] = map[string]any{
+			"type":       string(tp.Type),
+			"field":      tp.Field,
+			"expiration": tp.Expiration.String(),
+		}
+	}
+	if rp := md.RangePartitioning; rp != nil && rp.Range != nil {
+		v["range_partitioning"] = map[string]any{
+			"field":    rp.Field,
+			"start":    rp.Range.Start,
+			"end":      rp.Range.End,
+			"interval": rp.Range.Interval,
+		}
+	}
+	if c := md.Clustering; c != nil {
+		fields := make([]any, len(c.Fields))
+		for i, f := range c.Fields {
+			fields[i] = f
+		}
+		v["clustering"] = fields
+	}
+	return v, nil
+}
+
+func hasStatusCode(err error, code int) bool {
+	if e, ok := err.(*googleapi.Error); ok && e.Code == code {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package input
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// structuredRow converts a row loaded by the BigQuery client into a
+// structured message value, typed according to the schema of the row.
+func structuredRow(row map[string]bigquery.Value, schema bigquery.Schema) map[string]any {
+	out := make(map[string]any, len(row))
+	for _, f := range schema {
+		out[f.Name] = structuredValue(row[f.Name], f)
+	}
+	return out
+}
+
+// structuredValue converts a value of the field f. Records become objects,
+// NUMERIC and BIGNUMERIC values decimal strings that keep their precision,
+// and civil dates and times their canonical string form. Timestamps are kept
+// as time values.
+func structuredValue(v bigquery.Value, f *bigquery.FieldSchema) any {
+	switch t := v.(type) {
+	case map[string]bigquery.Value:
+		return structuredRow(t, f.Schema)
+	case []bigquery.Value:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = structuredValue(e, f)
+		}
+		return out
+	case *big.Rat:
+		if f.Type == bigquery.BigNumericFieldType {
+			return bigquery.BigNumericString(t)
+		}
+		return bigquery.NumericString(t)
+	case time.Time:
+		return t
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
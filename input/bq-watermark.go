@@ -0,0 +1,184 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// watermarkTimestampLayout formats timestamps as BigQuery timestamp strings,
+// which a STRING query parameter is coerced from when compared to a
+// TIMESTAMP column.
+const watermarkTimestampLayout = "2006-01-02 15:04:05.999999 UTC"
+
+// watermark checkpoints the progress of an input that reads incrementally,
+// optionally storing it in a cache resource so that it survives restarts.
+// Batches are acknowledged out of order, so the watermark only advances past a
+// batch once it and every batch emitted before it have been delivered. Inputs
+// using a watermark redeliver nacked batches until they are delivered, so
+// every tracked batch is eventually acknowledged.
+type watermark struct {
+	cache string
+	key   string
+
+	mu      sync.Mutex
+	value   string
+	pending []*watermarkBatch
+	drained chan struct{}
+
+	mgr *service.Resources
+}
+
+type watermarkBatch struct {
+	value string
+	acked bool
+}
+
+// newWatermark creates a watermark starting at initial. It is kept in memory
+// only when cache is empty.
+func newWatermark(cache, key, initial string, mgr *service.Resources) *watermark {
+	return &watermark{
+		cache: cache,
+		key:   key,
+		value: initial,
+		mgr:   mgr,
+	}
+}
+
+// load returns the current watermark, reading it from the cache when one has
+// been stored there. Batches that are still pending are not reflected in it,
+// so callers wait for them first to avoid reading their rows again.
+func (w *watermark) load(ctx context.Context) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cache == "" {
+		return w.value, nil
+	}
+
+	var cacheErr error
+	if err := w.mgr.AccessCache(ctx, w.cache, func(c service.Cache) {
+		b, err := c.Get(ctx, w.key)
+		if err != nil {
+			if !errors.Is(err, service.ErrKeyNotFound) {
+				cacheErr = err
+			}
+			return
+		}
+		w.value = string(b)
+	}); err != nil {
+		return "", fmt.Errorf("error accessing watermark cache: %w", err)
+	}
+	if cacheErr != nil {
+		return "", fmt.Errorf("error reading watermark: %w", cacheErr)
+	}
+	return w.value, nil
+}
+
+// wait blocks until every tracked batch has been acknowledged.
+func (w *watermark) wait(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		if len(w.pending) == 0 {
+			w.mu.Unlock()
+			return nil
+		}
+		if w.drained == nil {
+			w.drained = make(chan struct{})
+		}
+		drained := w.drained
+		w.mu.Unlock()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// current returns the watermark without reading it from the cache.
+func (w *watermark) current() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.value
+}
+
+// track registers a batch that advances the watermark to value, returning the
+// function that acknowledges it.
+func (w *watermark) track(value string) service.AckFunc {
+	b := &watermarkBatch{value: value}
+	w.mu.Lock()
+	w.pending = append(w.pending, b)
+	w.mu.Unlock()
+
+	return func(ctx context.Context, err error) error {
+		if err != nil {
+			// The batch is redelivered, it stays pending until then.
+			return nil
+		}
+		return w.ack(ctx, b)
+	}
+}
+
+// ack marks a batch as delivered and advances the watermark to the last batch
+// of the delivered prefix of pending batches.
+func (w *watermark) ack(ctx context.Context, b *watermarkBatch) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b.acked = true
+
+	var n int
+	for n < len(w.pending) && w.pending[n].acked {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	w.value = w.pending[n-1].value
+	w.pending = w.pending[n:]
+	if len(w.pending) == 0 && w.drained != nil {
+		close(w.drained)
+		w.drained = nil
+	}
+	if w.cache == "" {
+		return nil
+	}
+
+	var cacheErr error
+	if err := w.mgr.AccessCache(ctx, w.cache, func(c service.Cache) {
+		cacheErr = c.Set(ctx, w.key, []byte(w.value), nil)
+	}); err != nil {
+		return fmt.Errorf("error accessing watermark cache: %w", err)
+	}
+	if cacheErr != nil {
+		return fmt.Errorf("error storing watermark: %w", cacheErr)
+	}
+	return nil
+}
+
+// columnWatermark returns the value of the watermark column in row, formatted
+// as a query parameter.
+func columnWatermark(row map[string]bigquery.Value, schema bigquery.Schema, column string) (string, error) {
+	i := slices.IndexFunc(schema, func(f *bigquery.FieldSchema) bool {
+		return f.Name == column
+	})
+	if i < 0 {
+		return "", fmt.Errorf("watermark column %q is not in the query results", column)
+	}
+	switch t := structuredValue(row[column], schema[i]).(type) {
+	case nil:
+		return "", fmt.Errorf("watermark column %q is null", column)
+	case time.Time:
+		return t.UTC().Format(watermarkTimestampLayout), nil
+	case map[string]any, []any:
+		return "", fmt.Errorf("watermark column %q must be a scalar", column)
+	default:
+		return fmt.Sprint(t), nil
+	}
+}
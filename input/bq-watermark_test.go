@@ -0,0 +1,116 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestWatermarkOutOfOrderAcks(t *testing.T) {
+	tests := []struct {
+		name  string
+		acks  []int
+		nacks []int
+		want  []string
+	}{
+		{
+			name: "in order",
+			acks: []int{0, 1, 2},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "reversed",
+			acks: []int{2, 1, 0},
+			want: []string{"initial", "initial", "c"},
+		},
+		{
+			name: "middle first",
+			acks: []int{1, 0, 2},
+			want: []string{"initial", "b", "c"},
+		},
+		{
+			name:  "nacked batch holds the watermark",
+			nacks: []int{0},
+			acks:  []int{1, 2, 0},
+			want:  []string{"initial", "initial", "c"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			w := newWatermark("", "", "initial", nil)
+			ackFns := []service.AckFunc{w.track("a"), w.track("b"), w.track("c")}
+
+			for _, i := range test.nacks {
+				if err := ackFns[i](ctx, errors.New("nacked")); err != nil {
+					t.Fatal(err)
+				}
+				if got := w.current(); got != "initial" {
+					t.Fatalf("watermark after nack = %q, want %q", got, "initial")
+				}
+			}
+			for j, i := range test.acks {
+				if err := ackFns[i](ctx, nil); err != nil {
+					t.Fatal(err)
+				}
+				if got := w.current(); got != test.want[j] {
+					t.Errorf("watermark after ack %d = %q, want %q", i, got, test.want[j])
+				}
+			}
+		})
+	}
+}
+
+func TestWatermarkCache(t *testing.T) {
+	ctx := context.Background()
+	mgr := service.MockResources(service.MockResourcesOptAddCache("wm"))
+
+	w := newWatermark("wm", "key", "initial", mgr)
+	ackA, ackB := w.track("a"), w.track("b")
+	if err := ackB(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ackA(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newWatermark("wm", "key", "initial", mgr)
+	got, err := restarted.load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "b" {
+		t.Errorf("loaded watermark = %q, want %q", got, "b")
+	}
+}
+
+func TestWatermarkWait(t *testing.T) {
+	ctx := context.Background()
+	w := newWatermark("", "", "initial", nil)
+	ack := w.track("a")
+
+	done := make(chan error, 1)
+	go func() { done <- w.wait(ctx) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("wait returned with a pending batch: %v", err)
+	default:
+	}
+	if err := ack(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	w.track("b")
+	cancel()
+	if err := w.wait(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	_ "github.com/redpanda-data/connect/public/bundle/free/v4"
 
 	// Add your plugin packages here
+	_ "github.com/TubbyStubby/rp-connect-bq-stream/input"
 	_ "github.com/TubbyStubby/rp-connect-bq-stream/output"
 )
 
@@ -0,0 +1,49 @@
+package output
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptiveChunker tunes the effective number of rows per append based on
+// observed append latency and errors, growing by a tenth while appends stay
+// below the target latency and halving when they exceed it or fail.
+type adaptiveChunker struct {
+	mu   sync.Mutex
+	rows int
+
+	minRows       int
+	maxRows       int
+	targetLatency time.Duration
+}
+
+func newAdaptiveChunker(minRows, maxRows int, targetLatency time.Duration) *adaptiveChunker {
+	return &adaptiveChunker{
+		rows:          minRows,
+		minRows:       minRows,
+		maxRows:       maxRows,
+		targetLatency: targetLatency,
+	}
+}
+
+func (a *adaptiveChunker) limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rows
+}
+
+// observe records the outcome of an append of the given number of rows.
+func (a *adaptiveChunker) observe(rows int, latency time.Duration, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err != nil || latency > a.targetLatency {
+		a.rows = max(a.rows/2, a.minRows)
+		return
+	}
+	// Only full chunks tell us anything about whether larger ones would
+	// still meet the target.
+	if rows >= a.rows {
+		a.rows = min(a.rows+max(a.rows/10, 1), a.maxRows)
+	}
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// batchStats accumulates what happened to the rows of a single batch across
+// its appends, retries and the result collector, for its summary log.
+type batchStats struct {
+	converted atomic.Int64
+	appended  atomic.Int64
+	bytes     atomic.Int64
+	retries   atomic.Int64
+}
+
+type batchStatsKey struct{}
+
+func withBatchStats(ctx context.Context, s *batchStats) context.Context {
+	return context.WithValue(ctx, batchStatsKey{}, s)
+}
+
+// batchStatsFrom returns the stats of the batch ctx belongs to, or nil when
+// the append is not part of a batch, such as a spill replay.
+func batchStatsFrom(ctx context.Context) *batchStats {
+	s, _ := ctx.Value(batchStatsKey{}).(*batchStats)
+	return s
+}
+
+// recordAppended counts the rows and bytes of a successful append, and the
+// estimated cost of the bytes.
+func (g *gcpBigQueryOutput) recordAppended(ctx context.Context, rows [][]byte) {
+	var size int
+	for _, row := range rows {
+		size += len(row)
+	}
+	g.mRowsAppended.Incr(int64(len(rows)))
+	g.mBytesAppended.Incr(int64(size))
+	g.throughput.appended.Add(int64(len(rows)))
+	g.throughput.bytes.Add(int64(size))
+	if g.conf.CostPerGiB > 0 {
+		g.mCost.IncrFloat64(float64(size)/(1<<30)*g.conf.CostPerGiB, g.destination())
+	}
+	if s := batchStatsFrom(ctx); s != nil {
+		s.appended.Add(int64(len(rows)))
+		s.bytes.Add(int64(size))
+	}
+}
+
+// recordRetry counts a retried append.
+func (g *gcpBigQueryOutput) recordRetry(ctx context.Context) {
+	g.mRetries.Incr(1)
+	if s := batchStatsFrom(ctx); s != nil {
+		s.retries.Add(1)
+	}
+}
+
+// failedMessages returns the number of messages of a batch failed by err.
+func failedMessages(batch service.MessageBatch, err error) int {
+	var batchErr *service.BatchError
+	switch {
+	case errors.As(err, &batchErr):
+		return batchErr.IndexedErrors()
+	case err != nil:
+		return len(batch)
+	}
+	return 0
+}
+
+// logBatchSummary logs a structured summary of a written batch at debug
+// level.
+func (g *gcpBigQueryOutput) logBatchSummary(batch service.MessageBatch, s *batchStats, started time.Time, err error) {
+	l := g.log.With(
+		"destination", g.destination(),
+		"rows_in", len(batch),
+		"rows_converted", s.converted.Load(),
+		"rows_appended", s.appended.Load(),
+		"rows_failed", failedMessages(batch, err),
+		"bytes", s.bytes.Load(),
+		"retries", s.retries.Load(),
+		"duration", time.Since(started).String(),
+	)
+	if err != nil {
+		l = l.With("error", err.Error())
+	}
+	l.Debug("batch written")
+}
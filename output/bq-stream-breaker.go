@@ -0,0 +1,150 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops appends after a run of consecutive failures. While
+// open, writes fail fast until the cooldown has passed, after which a single
+// probe append is let through to decide whether to close it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether an append may be attempted.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of an append, reporting whether
+// it caused the breaker to open or to close again.
+func (b *circuitBreaker) record(err error) (opened, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		closed = b.state != breakerClosed
+		b.state = breakerClosed
+		b.failures = 0
+		b.probing = false
+		return false, closed
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		opened = b.state != breakerOpen
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+	return opened, false
+}
+
+// abandon releases the probe slot taken by an append that was abandoned
+// before it had an outcome, so that the next append probes instead.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// remaining returns how long the breaker stays open, or zero when appends
+// may be attempted.
+func (b *circuitBreaker) remaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerOpen {
+		return 0
+	}
+	return max(b.cooldown-time.Since(b.openedAt), 0)
+}
+
+// exitProcess terminates the process once max_consecutive_failures is
+// reached, replaced in tests.
+var exitProcess = os.Exit
+
+// recordAppend feeds the outcome of an append to the circuit breaker and the
+// consecutive failure limit. Appends abandoned because ctx is done say nothing
+// about the health of BigQuery and are not recorded.
+func (g *gcpBigQueryOutput) recordAppend(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		if g.breaker != nil {
+			g.breaker.abandon()
+		}
+		return
+	}
+	if g.errorClass(err) == errorFatal {
+		// BigQuery is healthy, it only rejected the rows.
+		err = nil
+	}
+	if g.breaker != nil {
+		switch opened, closed := g.breaker.record(err); {
+		case opened:
+			g.log.Errorf("circuit breaker opened after consecutive append failures, failing writes for %v: %v", g.conf.BreakerCooldown, err)
+			g.setState(streamStateFailed)
+			g.emit(eventBreakerOpen, err, 0)
+		case closed:
+			g.log.Infof("circuit breaker closed after a successful append to %v", g.conf.TableID)
+			g.setState(streamStateConnected)
+			g.emit(eventBreakerClose, nil, 0)
+		}
+	}
+	if g.conf.MaxConsecutiveFailures <= 0 {
+		return
+	}
+	if err == nil {
+		g.consecutiveFailures.Store(0)
+		return
+	}
+	if n := g.consecutiveFailures.Add(1); n >= int64(g.conf.MaxConsecutiveFailures) && g.exiting.CompareAndSwap(false, true) {
+		g.log.Errorf("exiting after %d consecutive append failures to %v: %v", n, g.conf.TableID, err)
+		// Close waits for the background goroutines of the output, so it is
+		// not called on the write path.
+		go func() {
+			if err := g.Close(context.Background()); err != nil {
+				g.log.Errorf("error closing output before exiting: %v", err)
+			}
+			exitProcess(1)
+		}()
+	}
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestRecordAppendMaxConsecutiveFailures(t *testing.T) {
+	exited := make(chan int, 1)
+	orig := exitProcess
+	exitProcess = func(code int) { exited <- code }
+	t.Cleanup(func() { exitProcess = orig })
+
+	g, err := newGCPBigQueryOutput(gcpBigQueryOutputConfig{TableID: "t", MaxConsecutiveFailures: 2}, service.MockResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("unavailable")
+
+	ctx := context.Background()
+	g.recordAppend(ctx, failure)
+	g.recordAppend(ctx, nil)
+	g.recordAppend(ctx, failure)
+
+	// Appends abandoned by the caller are not failures.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	g.recordAppend(cancelled, context.Canceled)
+
+	select {
+	case code := <-exited:
+		t.Fatalf("exited with %d before reaching the limit", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.recordAppend(ctx, failure)
+	g.recordAppend(ctx, failure)
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("did not exit after reaching the limit")
+	}
+	if g.shutdownCtx.Err() == nil {
+		t.Error("output was not closed before exiting")
+	}
+	select {
+	case code := <-exited:
+		t.Errorf("exited again with %d", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRecordAppendCancelledProbe(t *testing.T) {
+	g, err := newGCPBigQueryOutput(gcpBigQueryOutputConfig{TableID: "t", BreakerThreshold: 1, BreakerCooldown: time.Millisecond}, service.MockResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = g.Close(context.Background()) })
+
+	ctx := context.Background()
+	g.recordAppend(ctx, errors.New("unavailable"))
+	time.Sleep(5 * time.Millisecond)
+	if !g.breaker.allow() {
+		t.Fatal("probe not allowed after the cooldown")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	g.recordAppend(cancelled, context.Canceled)
+	if !g.breaker.allow() {
+		t.Fatal("probe not allowed after the previous probe was cancelled")
+	}
+
+	g.recordAppend(ctx, nil)
+	if !g.breaker.allow() || !g.breaker.allow() {
+		t.Error("breaker did not close after a successful probe")
+	}
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"sync"
+	"time"
+)
+
+// retryBudget is a token bucket limiting the rate of append retries. Outputs
+// configured with the same budget name share one bucket, so that an incident
+// affecting a whole project does not see every output retrying at full rate
+// against the same quota.
+type retryBudget struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// sharedRetryBudgets maps retry budget names to their retryBudget.
+var sharedRetryBudgets sync.Map
+
+// loadRetryBudget returns the retry budget registered under name, creating it
+// with the given rate and burst when it does not exist yet. The settings of
+// the output that creates a budget take effect for every output sharing it.
+func loadRetryBudget(name string, rate float64, burst int) *retryBudget {
+	if v, ok := sharedRetryBudgets.Load(name); ok {
+		return v.(*retryBudget)
+	}
+	v, _ := sharedRetryBudgets.LoadOrStore(name, &retryBudget{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	})
+	return v.(*retryBudget)
+}
+
+// take consumes a token for a retry, reporting false when the budget is
+// exhausted.
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryAllowed reports whether an append may be retried according to the
+// retry policy and the shared retry budget.
+func (g *gcpBigQueryOutput) retryAllowed(retries int, started time.Time) bool {
+	if !g.conf.Retry.allows(retries, started) {
+		return false
+	}
+	if g.retryBudget != nil && !g.retryBudget.take() {
+		g.log.Debugf("retry budget %v exhausted, not retrying append", g.conf.RetryBudgetName)
+		return false
+	}
+	return true
+}
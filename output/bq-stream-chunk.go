@@ -0,0 +1,33 @@
+package output
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxAppendBytes keeps the row data of a single AppendRows request safely
+// below the 10MB request limit, leaving headroom for the writer schema and
+// request framing.
+const maxAppendBytes = 9 * 1024 * 1024
+
+// rowChunk is a half-open range of rows sent in a single append.
+type rowChunk struct {
+	start, end int
+}
+
+// chunkRows splits rows into consecutive chunks holding at most maxRows rows
+// and maxBytes of encoded row data each, a maxRows of zero means no row
+// limit. A single row larger than maxBytes forms its own chunk and is left
+// for the API to reject.
+func chunkRows(rows [][]byte, maxRows, maxBytes int) []rowChunk {
+	var chunks []rowChunk
+	start, size := 0, 0
+	for i, r := range rows {
+		rowSize := protowire.SizeBytes(len(r)) + 1
+		if i > start && (size+rowSize > maxBytes || (maxRows > 0 && i-start >= maxRows)) {
+			chunks = append(chunks, rowChunk{start: start, end: i})
+			start, size = i, 0
+		}
+		size += rowSize
+	}
+	return append(chunks, rowChunk{start: start, end: len(rows)})
+}
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isNonRetryableError reports whether an append failed because the request
+// itself is invalid, such as rows not matching the table schema. Such appends
+// fail the same way however often they are retried.
+func isNonRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	s := errorStatus(err)
+	if s == nil {
+		return false
+	}
+	if s.Code() == codes.InvalidArgument {
+		return true
+	}
+	for _, d := range s.Details() {
+		if se, isStorageErr := d.(*storage.StorageError); isStorageErr {
+			switch se.GetCode() {
+			case storage.StorageError_SCHEMA_MISMATCH_EXTRA_FIELDS:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type errorClass int
+
+const (
+	errorUnclassified errorClass = iota
+	errorRetryable
+	errorReconnectable
+	errorFatal
+)
+
+var errorClassNames = map[string]errorClass{
+	"retryable":     errorRetryable,
+	"reconnectable": errorReconnectable,
+	"fatal":         errorFatal,
+}
+
+// normalizeErrorCode makes gRPC code and storage error code names comparable
+// regardless of their spelling, e.g. "INVALID_ARGUMENT" and "InvalidArgument".
+func normalizeErrorCode(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "_", ""))
+}
+
+func parseErrorClassification(m map[string]string) (map[string]errorClass, error) {
+	overrides := make(map[string]errorClass, len(m))
+	for code, name := range m {
+		class, ok := errorClassNames[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid error classification %q for %v, expected retryable, reconnectable or fatal", name, code)
+		}
+		overrides[normalizeErrorCode(code)] = class
+	}
+	return overrides, nil
+}
+
+// errorClass classifies an append error. Overrides configured for its storage
+// error code take precedence over those for its gRPC code, which take
+// precedence over the built-in classification.
+func (g *gcpBigQueryOutput) errorClass(err error) errorClass {
+	if err == nil {
+		return errorUnclassified
+	}
+	if len(g.conf.ErrorClassification) > 0 {
+		if s := errorStatus(err); s != nil {
+			for _, d := range s.Details() {
+				if se, ok := d.(*storage.StorageError); ok {
+					if class, ok := g.conf.ErrorClassification[normalizeErrorCode(se.GetCode().String())]; ok {
+						return class
+					}
+				}
+			}
+			if class, ok := g.conf.ErrorClassification[normalizeErrorCode(s.Code().String())]; ok {
+				return class
+			}
+		}
+	}
+	switch {
+	case isNonRetryableError(err):
+		return errorFatal
+	case isQuotaError(err):
+		return errorRetryable
+	case g.isReconnectableError(err):
+		return errorReconnectable
+	}
+	return errorUnclassified
+}
+
+// errorStatus returns the gRPC status of err, or nil when it has none.
+func errorStatus(err error) *status.Status {
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	if apiErr, ok := apierror.FromError(err); ok {
+		return apiErr.GRPCStatus()
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// pendingAppend is an append that has been sent on the stream and is waiting
+// for its result to be confirmed by the collector.
+type pendingAppend struct {
+	ms      *managedwriter.ManagedStream
+	result  *managedwriter.AppendResult
+	rows    [][]byte
+	started time.Time
+	stats   *batchStats
+	done    chan error
+
+	// failed holds the errors of individual rejected rows, and is set by the
+	// collector before done is signalled.
+	failed map[int]error
+}
+
+// resultCollector confirms append results out-of-band, so that writers can
+// keep sending appends on the stream while earlier ones are still in flight.
+// The queue is bounded, which applies backpressure to writers once too many
+// results are unconfirmed.
+type resultCollector struct {
+	queue    chan *pendingAppend
+	ctx      context.Context
+	shutdown context.CancelFunc
+	stopped  chan struct{}
+}
+
+func newResultCollector(size int) *resultCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &resultCollector{
+		queue:    make(chan *pendingAppend, size),
+		ctx:      ctx,
+		shutdown: cancel,
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (c *resultCollector) run(confirm func(context.Context, *pendingAppend) error) {
+	defer close(c.stopped)
+	for {
+		select {
+		case p := <-c.queue:
+			p.done <- confirm(c.ctx, p)
+		case <-c.ctx.Done():
+			for {
+				select {
+				case p := <-c.queue:
+					p.done <- service.ErrNotConnected
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *resultCollector) close() {
+	c.shutdown()
+	<-c.stopped
+}
+
+// writePipelined sends every chunk of the batch without waiting for the
+// result of the previous one, and then waits for the collector to confirm
+// each chunk. Only the rows of chunks that failed are reported as failed.
+func (g *gcpBigQueryOutput) writePipelined(ctx context.Context, batch service.MessageBatch, rows [][]byte, indexes []int, chunks []rowChunk, batchErr *service.BatchError) error {
+	pending := make([]*pendingAppend, len(chunks))
+	starts := make([]time.Time, len(chunks))
+	for ci, c := range chunks {
+		starts[ci] = time.Now()
+		p := &pendingAppend{rows: rows[c.start:c.end], started: starts[ci], stats: batchStatsFrom(ctx), done: make(chan error, 1)}
+		pending[ci] = p
+
+		ms, err := g.stream(ctx)
+		if err == nil {
+			p.ms = ms
+			p.result, err = g.appendRows(ctx, ms, p.rows)
+		}
+		if err != nil {
+			// Sending failed, retry it inline which reconnects when needed.
+			p.failed, err = g.resolveRowErrors(ctx, p.rows, g.appendWithRetry(ctx, p.rows, 0))
+			p.done <- err
+			continue
+		}
+		select {
+		case g.collector.queue <- p:
+		case <-ctx.Done():
+			p.done <- ctx.Err()
+		case <-g.collector.ctx.Done():
+			p.done <- service.ErrNotConnected
+		}
+	}
+
+	for ci, p := range pending {
+		var err error
+		select {
+		case err = <-p.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if g.failover != nil && p.ms != nil && g.recordPrimary(ctx, err) {
+			// Rows whose primary append failed are written to the failover
+			// table once the output failed over.
+			p.failed, err = g.resolveRowErrors(ctx, p.rows, g.appendFailover(ctx, p.rows))
+		}
+		g.mAppendLatency.Timing(time.Since(starts[ci]).Nanoseconds())
+		if g.adaptive != nil {
+			g.adaptive.observe(len(p.rows), time.Since(starts[ci]), err)
+		}
+		g.recordAppend(ctx, err)
+		for pos, rowErr := range p.failed {
+			batchErr = failBatchIndex(batchErr, batch, indexes[chunks[ci].start+pos], rowErr)
+		}
+		if g.errorClass(err) == errorFatal {
+			var rejected map[int]error
+			if rejected, err = g.rejectFatalChunk(ctx, p.rows, err); err == nil {
+				for pos, rowErr := range rejected {
+					batchErr = failBatchIndex(batchErr, batch, indexes[chunks[ci].start+pos], rowErr)
+				}
+				continue
+			}
+		}
+		if err = g.spillRows(p.rows, err); err == nil {
+			continue
+		}
+		if len(chunks) == 1 && batchErr == nil {
+			for _, msg := range batch {
+				annotateError(msg, err)
+			}
+			return err
+		}
+		for _, idx := range indexes[chunks[ci].start:chunks[ci].end] {
+			batchErr = failBatchIndex(batchErr, batch, idx, err)
+		}
+	}
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// confirmAppend waits for the result of a pending append, resending the rows
+// after a reconnect when the stream failed underneath it.
+func (g *gcpBigQueryOutput) confirmAppend(ctx context.Context, p *pendingAppend) error {
+	ctx = withBatchStats(ctx, p.stats)
+	o, err := g.getResult(ctx, p.result)
+	if err != nil {
+		g.reportQuota(err)
+		if isTableReplacedError(err) {
+			return g.resyncAfter(ctx, p.ms, err)
+		}
+		if g.errorClass(err) == errorRetryable && g.retryAllowed(0, p.started) {
+			p.failed, err = g.resolveRowErrors(ctx, p.rows, g.retryQuotaAppend(ctx, p.rows, 0, p.started, err))
+			return err
+		}
+		if !g.canRetry(err, 0, p.started) {
+			p.failed, err = g.resolveRowErrors(ctx, p.rows, withRowErrors(ctx, p.result, err))
+			return err
+		}
+		p.failed, err = g.resolveRowErrors(ctx, p.rows, g.retryAppend(ctx, p.ms, p.rows, 0, p.started, err, " on GetResult"))
+		return err
+	}
+	if o != managedwriter.NoStreamOffset {
+		return fmt.Errorf("offset mismatch, got %d want %d", o, managedwriter.NoStreamOffset)
+	}
+	g.recordAppended(ctx, p.rows)
+	return nil
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	quotedValuePattern  = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+	numericValuePattern = regexp.MustCompile(`\d+`)
+)
+
+// conversionSummary aggregates the conversion errors of a batch by class, so
+// that a producer emitting malformed messages yields one log line per batch
+// rather than one per message.
+type conversionSummary struct {
+	total   int
+	classes map[string]*conversionErrorClass
+}
+
+type conversionErrorClass struct {
+	class   string
+	count   int
+	example error
+}
+
+// conversionErrorKey derives the class of a conversion error from its message
+// by masking quoted and numeric values, which vary from row to row.
+func conversionErrorKey(err error) string {
+	key := quotedValuePattern.ReplaceAllString(err.Error(), `"…"`)
+	return numericValuePattern.ReplaceAllString(key, "N")
+}
+
+func (s *conversionSummary) add(err error) {
+	if s.classes == nil {
+		s.classes = map[string]*conversionErrorClass{}
+	}
+	s.total++
+	key := conversionErrorKey(err)
+	c, ok := s.classes[key]
+	if !ok {
+		c = &conversionErrorClass{class: key, example: err}
+		s.classes[key] = c
+	}
+	c.count++
+}
+
+// key identifies the combination of error classes of the summary, so that
+// summaries of batches failing for the same reasons can be sampled together.
+func (s *conversionSummary) key() string {
+	keys := make([]string, 0, len(s.classes))
+	for k := range s.classes {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, "; ")
+}
+
+// String lists the error classes from most to least frequent, each with its
+// count and the first error of the class as an example.
+func (s *conversionSummary) String() string {
+	classes := make([]*conversionErrorClass, 0, len(s.classes))
+	for _, c := range s.classes {
+		classes = append(classes, c)
+	}
+	slices.SortFunc(classes, func(a, b *conversionErrorClass) int {
+		if n := cmp.Compare(b.count, a.count); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.class, b.class)
+	})
+	var sb strings.Builder
+	for i, c := range classes {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		fmt.Fprintf(&sb, "%d× %s (e.g. %v)", c.count, c.class, c.example)
+	}
+	return sb.String()
+}
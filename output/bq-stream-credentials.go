@@ -0,0 +1,113 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// credentialsInfo holds the fields of a credentials JSON file that are
+// checked before the file is handed to the client libraries, whose errors for
+// malformed credentials only surface on the first request.
+type credentialsInfo struct {
+	Type             string          `json:"type"`
+	Audience         string          `json:"audience"`
+	SubjectTokenType string          `json:"subject_token_type"`
+	TokenURL         string          `json:"token_url"`
+	CredentialSource json.RawMessage `json:"credential_source"`
+}
+
+var supportedCredentialTypes = []string{
+	"service_account",
+	"authorized_user",
+	"impersonated_service_account",
+	"external_account",
+	"external_account_authorized_user",
+}
+
+// validateCredentials checks that b holds credentials of a supported type.
+// External account credentials, as used by Workload Identity Federation from
+// AWS, Azure or OIDC providers, must describe a complete token exchange, as
+// the subject token is exchanged for a Google access token through the
+// security token service before every token refresh.
+func validateCredentials(b []byte) error {
+	var info credentialsInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return fmt.Errorf("invalid credentials: %w", err)
+	}
+	if !slices.Contains(supportedCredentialTypes, info.Type) {
+		return fmt.Errorf("unsupported credentials type %q, expected one of %s", info.Type, strings.Join(supportedCredentialTypes, ", "))
+	}
+	if info.Type != "external_account" {
+		return nil
+	}
+	var missing []string
+	if info.Audience == "" {
+		missing = append(missing, "audience")
+	}
+	if info.SubjectTokenType == "" {
+		missing = append(missing, "subject_token_type")
+	}
+	if info.TokenURL == "" {
+		missing = append(missing, "token_url")
+	}
+	if len(info.CredentialSource) == 0 || string(info.CredentialSource) == "null" {
+		missing = append(missing, "credential_source")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("external account credentials are missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// resolveCredentials returns the configured credentials, evaluating the
+// interpolations of credentials_json or reading credentials_file, or nil when
+// Application Default Credentials are used.
+func (conf gcpBigQueryOutputConfig) resolveCredentials() ([]byte, error) {
+	if conf.CredentialsFile != "" {
+		b, err := os.ReadFile(conf.CredentialsFile)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("credentials file %v does not exist", conf.CredentialsFile)
+			}
+			return nil, fmt.Errorf("error reading credentials file: %w", err)
+		}
+		if err := validateCredentials(b); err != nil {
+			return nil, fmt.Errorf("credentials file %v: %w", conf.CredentialsFile, err)
+		}
+		return b, nil
+	}
+	if conf.CredentialsJSON == nil {
+		return nil, nil
+	}
+	b, err := conf.CredentialsJSON.TryBytes(service.NewMessage(nil))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving credentials_json: %w", err)
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if err := validateCredentials(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// credentialsFingerprint identifies the resolved credentials, so that a
+// reconnect can tell whether they were rotated since the clients were
+// created. Credentials that cannot be resolved yield an empty fingerprint.
+func (conf gcpBigQueryOutputConfig) credentialsFingerprint() string {
+	b, err := conf.resolveCredentials()
+	if err != nil || len(b) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,40 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// rowDeduper derives keys identifying duplicate rows within a batch, either
+// from the whole message or from the values of a set of key columns.
+type rowDeduper struct {
+	keyColumns []string
+}
+
+func newRowDeduper(keyColumns []string) *rowDeduper {
+	return &rowDeduper{keyColumns: keyColumns}
+}
+
+// key returns the dedupe key of a message. Key column values are compared by
+// their compacted JSON encoding, and missing columns are treated as null.
+func (d *rowDeduper) key(msgBytes []byte) (string, error) {
+	if len(d.keyColumns) == 0 {
+		return string(msgBytes), nil
+	}
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(msgBytes, &row); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, column := range d.keyColumns {
+		v, ok := row[column]
+		if !ok {
+			v = json.RawMessage("null")
+		}
+		if err := json.Compact(&buf, v); err != nil {
+			return "", err
+		}
+		buf.WriteByte(0)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,320 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fastEncoder writes decoded JSON rows directly into proto wire bytes using a
+// field table derived from the table descriptor, bypassing dynamicpb and
+// protojson. It mirrors the protojson semantics for the field kinds produced
+// from BigQuery schemas.
+type fastEncoder struct {
+	fields   map[string]*fastField
+	required []*fastField
+	// numFields is the number of distinct fields, which fields holds under
+	// both their proto and JSON names.
+	numFields int
+
+	// flat is true when every field is a singular scalar, which allows rows
+	// to be encoded straight from the JSON tokens.
+	flat           bool
+	discardUnknown bool
+
+	// discarded counts unknown fields dropped by discardUnknown.
+	discarded *service.MetricCounter
+}
+
+type fastField struct {
+	name    string
+	number  protowire.Number
+	kind    protoreflect.Kind
+	list    bool
+	packed  bool
+	message *fastEncoder
+
+	// index identifies the field among the fields of its encoder.
+	index int
+
+	// requiredBit identifies required fields of flat encoders.
+	requiredBit uint64
+}
+
+// newFastEncoder builds an encoder for md, returning false when the
+// descriptor contains field kinds the encoder does not support, in which case
+// the protojson path should be used instead. When skipRequired is set,
+// required fields are not checked for presence. Unknown fields dropped by
+// discardUnknown are counted by discarded.
+func newFastEncoder(md protoreflect.MessageDescriptor, discardUnknown, skipRequired bool, discarded *service.MetricCounter) (*fastEncoder, bool) {
+	fds := md.Fields()
+	e := &fastEncoder{
+		fields:         make(map[string]*fastField, fds.Len()*2),
+		numFields:      fds.Len(),
+		flat:           true,
+		discardUnknown: discardUnknown,
+		discarded:      discarded,
+	}
+	for i := 0; i < fds.Len(); i++ {
+		fd := fds.Get(i)
+		if fd.IsMap() || fd.ContainingOneof() != nil {
+			return nil, false
+		}
+		f := &fastField{
+			name:   string(fd.Name()),
+			number: fd.Number(),
+			kind:   fd.Kind(),
+			list:   fd.IsList(),
+			packed: fd.IsPacked(),
+			index:  i,
+		}
+		switch fd.Kind() {
+		case protoreflect.BoolKind,
+			protoreflect.Int32Kind,
+			protoreflect.Int64Kind,
+			protoreflect.DoubleKind,
+			protoreflect.StringKind,
+			protoreflect.BytesKind:
+		case protoreflect.MessageKind:
+			var ok bool
+			if f.message, ok = newFastEncoder(fd.Message(), discardUnknown, skipRequired, discarded); !ok {
+				return nil, false
+			}
+			e.flat = false
+		default:
+			return nil, false
+		}
+		if f.list {
+			e.flat = false
+		}
+		e.fields[string(fd.Name())] = f
+		e.fields[fd.JSONName()] = f
+		if fd.Cardinality() == protoreflect.Required && !skipRequired {
+			if len(e.required) < 64 {
+				f.requiredBit = 1 << len(e.required)
+			} else {
+				e.flat = false
+			}
+			e.required = append(e.required, f)
+		}
+	}
+	return e, true
+}
+
+func (e *fastEncoder) encode(b []byte, row map[string]any) ([]byte, error) {
+	var seen map[*fastField]struct{}
+	if len(e.required) > 0 {
+		seen = make(map[*fastField]struct{}, len(e.required))
+	}
+	for k, v := range row {
+		f, ok := e.fields[k]
+		if !ok {
+			if e.discardUnknown {
+				e.discarded.Incr(1)
+				continue
+			}
+			return nil, fmt.Errorf("unknown field %q", k)
+		}
+		if v == nil {
+			continue
+		}
+		var err error
+		if b, err = f.encode(b, v); err != nil {
+			return nil, fmt.Errorf("invalid value for %v field %v: %w", f.kind, f.name, err)
+		}
+		if seen != nil {
+			seen[f] = struct{}{}
+		}
+	}
+	for _, f := range e.required {
+		if _, ok := seen[f]; !ok {
+			return nil, fmt.Errorf("required field %v not set", f.name)
+		}
+	}
+	return b, nil
+}
+
+func (f *fastField) encode(b []byte, v any) ([]byte, error) {
+	if !f.list {
+		return f.encodeSingular(b, v)
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected array, got %T", v)
+	}
+	if !f.packed {
+		for _, e := range arr {
+			if e == nil {
+				return nil, errors.New("unexpected null in array")
+			}
+			var err error
+			if b, err = f.encodeSingular(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	if len(arr) == 0 {
+		return b, nil
+	}
+	var packed []byte
+	for _, e := range arr {
+		if e == nil {
+			return nil, errors.New("unexpected null in array")
+		}
+		var err error
+		if packed, err = f.appendScalar(packed, e); err != nil {
+			return nil, err
+		}
+	}
+	b = protowire.AppendTag(b, f.number, protowire.BytesType)
+	return protowire.AppendBytes(b, packed), nil
+}
+
+func (f *fastField) encodeSingular(b []byte, v any) ([]byte, error) {
+	switch f.kind {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind:
+		b = protowire.AppendTag(b, f.number, protowire.BytesType)
+	case protoreflect.DoubleKind:
+		b = protowire.AppendTag(b, f.number, protowire.Fixed64Type)
+	default:
+		b = protowire.AppendTag(b, f.number, protowire.VarintType)
+	}
+	return f.appendScalar(b, v)
+}
+
+// appendScalar appends the value without its tag.
+func (f *fastField) appendScalar(b []byte, v any) ([]byte, error) {
+	switch f.kind {
+	case protoreflect.BoolKind:
+		x, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %T", v)
+		}
+		return protowire.AppendVarint(b, protowire.EncodeBool(x)), nil
+	case protoreflect.Int32Kind:
+		n, err := jsonInt(v, 32)
+		if err != nil {
+			return nil, err
+		}
+		return protowire.AppendVarint(b, uint64(n)), nil
+	case protoreflect.Int64Kind:
+		n, err := jsonInt(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		return protowire.AppendVarint(b, uint64(n)), nil
+	case protoreflect.DoubleKind:
+		x, err := jsonFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		return protowire.AppendFixed64(b, math.Float64bits(x)), nil
+	case protoreflect.StringKind:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", v)
+		}
+		return protowire.AppendString(b, s), nil
+	case protoreflect.BytesKind:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected base64 string, got %T", v)
+		}
+		bs, err := decodeJSONBytes(s)
+		if err != nil {
+			return nil, err
+		}
+		return protowire.AppendBytes(b, bs), nil
+	case protoreflect.MessageKind:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected object, got %T", v)
+		}
+		scratch := scratchPool.Get().(*[]byte)
+		defer putScratch(scratch)
+		nested, err := f.message.encode((*scratch)[:0], obj)
+		if err != nil {
+			return nil, err
+		}
+		*scratch = nested
+		return protowire.AppendBytes(b, nested), nil
+	}
+	return nil, fmt.Errorf("unsupported field kind %v", f.kind)
+}
+
+// jsonInt accepts JSON numbers and numeric strings holding an integral value,
+// including exponent forms such as 1e3, as protojson does.
+func jsonInt(v any, bitSize int) (int64, error) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = string(x)
+	case string:
+		if s = x; strings.TrimSpace(s) != s {
+			return 0, fmt.Errorf("invalid integer %q", x)
+		}
+	default:
+		return 0, fmt.Errorf("expected number, got %T", v)
+	}
+	if n, err := strconv.ParseInt(s, 10, bitSize); err == nil {
+		return n, nil
+	}
+	f, _, err := big.ParseFloat(s, 10, 256, big.ToNearestEven)
+	if err != nil || !f.IsInt() {
+		return 0, fmt.Errorf("invalid integer %q", s)
+	}
+	n, acc := f.Int64()
+	if acc != big.Exact || (bitSize == 32 && (n < math.MinInt32 || n > math.MaxInt32)) {
+		return 0, fmt.Errorf("integer %q out of range", s)
+	}
+	return n, nil
+}
+
+func jsonFloat(v any) (float64, error) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = string(x)
+	case string:
+		switch x {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		if s = x; strings.TrimSpace(s) != s {
+			return 0, fmt.Errorf("invalid number %q", x)
+		}
+	default:
+		return 0, fmt.Errorf("expected number, got %T", v)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// decodeJSONBytes decodes standard or URL-safe base64 with optional padding.
+func decodeJSONBytes(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
@@ -0,0 +1,47 @@
+package output
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/grpc/status"
+)
+
+// errorFieldPattern extracts the offending field from conversion errors and
+// BigQuery row error messages, which name it as "field <name>".
+var errorFieldPattern = regexp.MustCompile(`field "?([A-Za-z_][\w.]*)"?`)
+
+// annotateError attaches structured details of err to msg as metadata, so
+// that fallback and dead letter outputs can route and triage failed rows.
+func annotateError(msg *service.Message, err error) {
+	msg.MetaSetMut("bigquery_error", err.Error())
+
+	var rowErrs *appendRowErrors
+	if errors.As(err, &rowErrs) {
+		err = rowErrs.err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		if apiErr, isAPIErr := apierror.FromError(err); isAPIErr && apiErr.GRPCStatus() != nil {
+			s, ok = apiErr.GRPCStatus(), true
+		}
+	}
+	if ok {
+		msg.MetaSetMut("bigquery_grpc_code", s.Code().String())
+		for _, d := range s.Details() {
+			if se, isStorageErr := d.(*storage.StorageError); isStorageErr {
+				msg.MetaSetMut("bigquery_storage_error_code", se.GetCode().String())
+				msg.MetaSetMut("bigquery_error_entity", se.GetEntity())
+				if se.GetErrorMessage() != "" {
+					msg.MetaSetMut("bigquery_error_message", se.GetErrorMessage())
+				}
+			}
+		}
+	}
+	if m := errorFieldPattern.FindStringSubmatch(err.Error()); m != nil {
+		msg.MetaSetMut("bigquery_error_field", m[1])
+	}
+}
@@ -0,0 +1,177 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// errorPayloadMaxBytes caps the payload written to the errors table, so that
+// rows rejected for their size still fit in an append.
+const errorPayloadMaxBytes = 1024 * 1024
+
+// errorSink writes permanently rejected rows to an errors table through its
+// own managed stream, acting as a dead letter destination.
+type errorSink struct {
+	ms  *managedwriter.ManagedStream
+	md  protoreflect.MessageDescriptor
+	umo protojson.UnmarshalOptions
+
+	// jsonPayload is set when the payload column is of type JSON.
+	jsonPayload bool
+}
+
+// openErrorSink opens the managed stream of the errors table, which keeps ctx
+// for its lifetime.
+func (g *gcpBigQueryOutput) openErrorSink(ctx context.Context, client *bigquery.Client, mwClient *managedwriter.Client) (*errorSink, error) {
+	ts, err := g.tableSchemaOf(ctx, client, mwClient, g.conf.ErrorsTable)
+	if err != nil {
+		return nil, fmt.Errorf("errors table: %w", err)
+	}
+	md, dp, err := getDescriptor(ts)
+	if err != nil {
+		return nil, fmt.Errorf("errors table: %w", err)
+	}
+	ms, err := mwClient.NewManagedStream(ctx,
+		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(g.conf.ProjectID, g.conf.DatasetID, g.conf.ErrorsTable)),
+		managedwriter.WithType(managedwriter.DefaultStream),
+		managedwriter.WithSchemaDescriptor(dp),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating BigQuery managed stream for errors table: %w", err)
+	}
+	sink := &errorSink{
+		ms:  ms,
+		md:  md,
+		umo: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}
+	for _, f := range ts.GetFields() {
+		if f.GetName() == "payload" {
+			sink.jsonPayload = f.GetType() == storage.TableFieldSchema_JSON
+		}
+	}
+	return sink, nil
+}
+
+// row builds the errors table row of a rejected message. Columns that are
+// missing from the errors table are left out. Payloads larger than
+// errorPayloadMaxBytes are truncated, and written as a JSON string to JSON
+// payload columns, which a truncated document is not valid for.
+func (s *errorSink) row(msg *service.Message, err error, destination string, failedAt time.Time) ([]byte, error) {
+	b, _ := msg.AsBytes()
+	payload := string(b)
+	if len(payload) > errorPayloadMaxBytes {
+		err = fmt.Errorf("%w (payload truncated from %d bytes)", err, len(payload))
+		payload = truncateString(payload, errorPayloadMaxBytes)
+		if s.jsonPayload {
+			quoted, jerr := json.Marshal(payload)
+			if jerr != nil {
+				return nil, jerr
+			}
+			payload = string(quoted)
+		}
+	}
+	meta := map[string]any{}
+	_ = msg.MetaWalkMut(func(k string, v any) error {
+		meta[k] = v
+		return nil
+	})
+	metaBytes, jerr := json.Marshal(meta)
+	if jerr != nil {
+		return nil, jerr
+	}
+	rowJSON, jerr := json.Marshal(map[string]any{
+		"payload":     payload,
+		"error":       err.Error(),
+		"destination": destination,
+		"failed_at":   failedAt.UnixMicro(),
+		"metadata":    string(metaBytes),
+	})
+	if jerr != nil {
+		return nil, jerr
+	}
+	m := dynamicpb.NewMessage(s.md)
+	if err := s.umo.Unmarshal(rowJSON, m); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+func (s *errorSink) close() {
+	s.ms.Close()
+}
+
+// divertRejected writes the permanently rejected rows of a failed batch to
+// the errors table and returns the remaining failures, if any. When the
+// errors table cannot be written the original batch error is returned.
+func (g *gcpBigQueryOutput) divertRejected(ctx context.Context, batch service.MessageBatch, batchErr *service.BatchError) error {
+	g.connMut.RLock()
+	sink := g.errorSink
+	g.connMut.RUnlock()
+	if sink == nil {
+		return batchErr
+	}
+
+	destination := g.destination()
+	now := time.Now()
+	var rows [][]byte
+	var diverted []int
+	var divertedErrs []error
+	var remaining *service.BatchError
+	batchErr.WalkMessagesIndexedBy(batch.Index(), func(i int, msg *service.Message, err error) bool {
+		if err == nil {
+			return true
+		}
+		var rejected *rowRejectedError
+		if errors.As(err, &rejected) {
+			row, rerr := sink.row(msg, err, destination, now)
+			if rerr == nil {
+				rows = append(rows, row)
+				diverted = append(diverted, i)
+				divertedErrs = append(divertedErrs, err)
+				return true
+			}
+			g.logRowError(g.log.Errorf, "errors_table_row:"+conversionErrorKey(rerr), "unable to build errors table row: %v", rerr)
+		}
+		remaining = failBatchIndex(remaining, batch, i, err)
+		return true
+	})
+	if len(rows) == 0 {
+		return batchErr
+	}
+
+	// Rows of chunks that cannot be written keep their original errors.
+	var written int
+	for _, c := range chunkRows(rows, 0, g.conf.MaxBytesPerAppend) {
+		result, err := g.appendRows(ctx, sink.ms, rows[c.start:c.end])
+		if err == nil {
+			_, err = g.getResult(ctx, result)
+		}
+		if err != nil {
+			g.log.Errorf("failed to write %d rejected rows to errors table %v: %v", c.end-c.start, g.conf.ErrorsTable, err)
+			for j := c.start; j < c.end; j++ {
+				remaining = failBatchIndex(remaining, batch, diverted[j], divertedErrs[j])
+			}
+			continue
+		}
+		written += c.end - c.start
+	}
+	if written > 0 {
+		g.log.Debugf("wrote %d rejected rows to errors table %v", written, g.conf.ErrorsTable)
+	}
+	if remaining == nil {
+		return nil
+	}
+	return remaining
+}
@@ -0,0 +1,184 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// failoverDestination switches appends to a secondary table after sustained
+// failures of the primary table, and back once a probe append to the primary
+// table succeeds again.
+type failoverDestination struct {
+	table         string
+	after         int
+	probeInterval time.Duration
+
+	mu        sync.Mutex
+	ms        *managedwriter.ManagedStream
+	client    *managedwriter.Client
+	failures  int
+	active    bool
+	lastProbe time.Time
+}
+
+func newFailoverDestination(project, dataset, table string, after int, probeInterval time.Duration) *failoverDestination {
+	return &failoverDestination{
+		table:         managedwriter.TableParentFromParts(project, dataset, table),
+		after:         after,
+		probeInterval: probeInterval,
+	}
+}
+
+func (f *failoverDestination) setTable(project, dataset, table string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.table = managedwriter.TableParentFromParts(project, dataset, table)
+}
+
+// useSecondary reports whether the next append should go to the secondary
+// table. While failed over, an append is let through to the primary table
+// every probe interval.
+func (f *failoverDestination) useSecondary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active {
+		return false
+	}
+	if time.Since(f.lastProbe) < f.probeInterval {
+		return true
+	}
+	f.lastProbe = time.Now()
+	return false
+}
+
+// record feeds the outcome of an append to the primary table, reporting
+// whether the output has failed over and the rows should be appended to the
+// secondary table instead.
+func (f *failoverDestination) record(err error) (active, changed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		f.failures = 0
+		changed, f.active = f.active, false
+		return false, changed
+	}
+	f.failures++
+	if !f.active && f.failures >= f.after {
+		f.active = true
+		f.lastProbe = time.Now()
+		return true, true
+	}
+	return f.active, false
+}
+
+func (f *failoverDestination) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ms != nil {
+		f.ms.Close()
+		f.ms = nil
+		f.client = nil
+	}
+}
+
+func (f *failoverDestination) isActive() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// appendPrimary appends rows to the primary table, falling back to the
+// failover table after sustained failures.
+func (g *gcpBigQueryOutput) appendPrimary(ctx context.Context, rows [][]byte, retryCount int) error {
+	if g.failover.useSecondary() {
+		return g.appendFailover(ctx, rows)
+	}
+	err := g.appendAttempt(ctx, rows, retryCount, time.Now())
+	if g.recordPrimary(ctx, err) {
+		return g.appendFailover(ctx, rows)
+	}
+	return err
+}
+
+// recordPrimary feeds the outcome of an append to the primary table to the
+// failover destination, reporting whether the output is failed over. Rows
+// rejected by BigQuery do not indicate a failing table and are not counted.
+func (g *gcpBigQueryOutput) recordPrimary(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || g.errorClass(err) == errorFatal || errors.As(err, new(*appendRowErrors)) {
+		return false
+	}
+	active, changed := g.failover.record(err)
+	if changed && active {
+		g.log.Errorf("failing over from table %v to %v after %d consecutive append failures: %v", g.conf.TableID, g.failover.table, g.failover.after, err)
+	} else if changed {
+		g.log.Infof("table %v recovered, failing back from %v", g.conf.TableID, g.failover.table)
+	}
+	return active
+}
+
+// appendFailover appends rows to the failover table, opening its stream on
+// first use and reopening it after an append fails.
+func (g *gcpBigQueryOutput) appendFailover(ctx context.Context, rows [][]byte) error {
+	ms, err := g.failoverStream(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := g.appendRows(ctx, ms, rows)
+	if err == nil {
+		var o int64
+		if o, err = g.getResult(ctx, result); err != nil {
+			err = withRowErrors(ctx, result, err)
+		} else if o != managedwriter.NoStreamOffset {
+			err = fmt.Errorf("offset mismatch, got %d want %d", o, managedwriter.NoStreamOffset)
+		}
+	}
+	if err != nil && g.errorClass(err) == errorReconnectable {
+		g.failover.close()
+	}
+	if err != nil {
+		return fmt.Errorf("error appending to failover table %v: %w", g.failover.table, err)
+	}
+	g.recordAppended(ctx, rows)
+	return nil
+}
+
+// failoverStream returns the stream of the failover table, opening it on the
+// current managed writer client. A stream opened on a client that has since
+// been replaced is closed and opened again.
+func (g *gcpBigQueryOutput) failoverStream(ctx context.Context) (*managedwriter.ManagedStream, error) {
+	g.connMut.RLock()
+	mwClient, dp := g.mwClient, g.descriptorProto
+	g.connMut.RUnlock()
+	if mwClient == nil {
+		return nil, service.ErrNotConnected
+	}
+
+	g.failover.mu.Lock()
+	defer g.failover.mu.Unlock()
+	if g.failover.ms != nil {
+		if g.failover.client == mwClient {
+			return g.failover.ms, nil
+		}
+		g.failover.ms.Close()
+		g.failover.ms = nil
+	}
+
+	// Rows are encoded for the primary table, so the failover table is
+	// written with the same descriptor and must have a compatible schema.
+	// The destination option given last takes precedence.
+	// The stream keeps its context for its lifetime and is used by later
+	// batches, so it is opened on the lifetime context of the output.
+	opts := append(g.streamOptions(ctx, dp), managedwriter.WithDestinationTable(g.failover.table))
+	ms, err := mwClient.NewManagedStream(g.shutdownCtx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating managed stream for failover table %v: %w", g.failover.table, err)
+	}
+	g.failover.ms, g.failover.client = ms, mwClient
+	return ms, nil
+}
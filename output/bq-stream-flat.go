@@ -0,0 +1,313 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// errFlatFallback signals that a message cannot be handled by the flat
+// encoder and should take the generic decode path instead, which also
+// produces the appropriate error for malformed documents.
+var errFlatFallback = errors.New("flat encoding not applicable")
+
+// encodeFlat encodes a JSON object straight from its raw bytes into proto wire
+// bytes without building intermediate maps or messages. It only applies to
+// encoders whose fields are all top-level scalars, and falls back on anything
+// it does not handle such as nested values or escaped keys.
+func (e *fastEncoder) encodeFlat(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	var seen uint64
+
+	// present tracks the fields read so far to reject duplicate keys, as
+	// protojson does, without allocating for tables of up to 256 columns.
+	var small [4]uint64
+	present := small[:]
+	if words := (e.numFields + 63) / 64; words > len(small) {
+		present = make([]uint64, words)
+	}
+
+	i := skipJSONSpace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return nil, errFlatFallback
+	}
+	i++
+	for first := true; ; first = false {
+		if i = skipJSONSpace(data, i); i >= len(data) {
+			return nil, errFlatFallback
+		}
+		if data[i] == '}' {
+			i++
+			break
+		}
+		if !first {
+			if data[i] != ',' {
+				return nil, errFlatFallback
+			}
+			i = skipJSONSpace(data, i+1)
+		}
+
+		if i >= len(data) || data[i] != '"' {
+			return nil, errFlatFallback
+		}
+		keyEnd, escaped := scanJSONString(data, i+1)
+		if keyEnd < 0 || escaped {
+			return nil, errFlatFallback
+		}
+		key := data[i+1 : keyEnd]
+		if i = skipJSONSpace(data, keyEnd+1); i >= len(data) || data[i] != ':' {
+			return nil, errFlatFallback
+		}
+		if i = skipJSONSpace(data, i+1); i >= len(data) {
+			return nil, errFlatFallback
+		}
+
+		f, ok := e.fields[string(key)]
+		if !ok {
+			if !e.discardUnknown {
+				return nil, fmt.Errorf("unknown field %q", key)
+			}
+			// skipJSONValue only delimits the value, the generic path reports
+			// values that are not valid JSON.
+			end := skipJSONValue(data, i)
+			if end < 0 || !json.Valid(data[i:end]) {
+				return nil, errFlatFallback
+			}
+			i = end
+			e.discarded.Incr(1)
+			continue
+		}
+
+		word, bit := f.index/64, uint64(1)<<(f.index%64)
+		if present[word]&bit != 0 {
+			return nil, fmt.Errorf("duplicate field %q", key)
+		}
+		present[word] |= bit
+
+		var set bool
+		var err error
+		if out, i, set, err = f.appendFlatValue(out, data, i); err != nil {
+			if errors.Is(err, errFlatFallback) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("invalid value for %v field %v: %w", f.kind, f.name, err)
+		}
+		if set {
+			seen |= f.requiredBit
+		}
+	}
+	if skipJSONSpace(data, i) != len(data) {
+		return nil, errFlatFallback
+	}
+	for _, f := range e.required {
+		if seen&f.requiredBit == 0 {
+			return nil, fmt.Errorf("required field %v not set", f.name)
+		}
+	}
+	return out, nil
+}
+
+// appendFlatValue encodes the scalar JSON value starting at data[i], returning
+// the index following it and whether a value was written.
+func (f *fastField) appendFlatValue(out, data []byte, i int) ([]byte, int, bool, error) {
+	switch c := data[i]; {
+	case c == 'n':
+		if !hasJSONLiteral(data, i, "null") {
+			return nil, 0, false, errFlatFallback
+		}
+		return out, i + 4, false, nil
+	case c == 't' || c == 'f':
+		v := c == 't'
+		lit := "false"
+		if v {
+			lit = "true"
+		}
+		if !hasJSONLiteral(data, i, lit) {
+			return nil, 0, false, errFlatFallback
+		}
+		out, err := f.encodeSingular(out, v)
+		return out, i + len(lit), true, err
+	case c == '"':
+		end, escaped := scanJSONString(data, i+1)
+		if end < 0 {
+			return nil, 0, false, errFlatFallback
+		}
+		raw := data[i+1 : end]
+		if f.kind == protoreflect.StringKind && !escaped && utf8.Valid(raw) {
+			out = protowire.AppendTag(out, f.number, protowire.BytesType)
+			return protowire.AppendBytes(out, raw), end + 1, true, nil
+		}
+		var s string
+		if escaped || !utf8.Valid(raw) {
+			if err := json.Unmarshal(data[i:end+1], &s); err != nil {
+				return nil, 0, false, errFlatFallback
+			}
+		} else {
+			s = string(raw)
+		}
+		out, err := f.encodeSingular(out, s)
+		return out, end + 1, true, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		end := scanJSONNumber(data, i)
+		if end < 0 {
+			return nil, 0, false, errFlatFallback
+		}
+		raw := data[i:end]
+		switch f.kind {
+		case protoreflect.Int32Kind, protoreflect.Int64Kind:
+			if n, ok := parseJSONDigits(raw); ok && (f.kind == protoreflect.Int64Kind || (n >= math.MinInt32 && n <= math.MaxInt32)) {
+				out = protowire.AppendTag(out, f.number, protowire.VarintType)
+				return protowire.AppendVarint(out, uint64(n)), end, true, nil
+			}
+		case protoreflect.DoubleKind:
+			x, err := strconv.ParseFloat(string(raw), 64)
+			if err != nil {
+				return nil, 0, false, fmt.Errorf("invalid number %q", raw)
+			}
+			out = protowire.AppendTag(out, f.number, protowire.Fixed64Type)
+			return protowire.AppendFixed64(out, math.Float64bits(x)), end, true, nil
+		}
+		out, err := f.encodeSingular(out, json.Number(raw))
+		return out, end, true, err
+	}
+	return nil, 0, false, errFlatFallback
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func hasJSONLiteral(data []byte, i int, lit string) bool {
+	return len(data)-i >= len(lit) && string(data[i:i+len(lit)]) == lit
+}
+
+// scanJSONString returns the index of the closing quote of a string whose
+// contents start at data[i], and whether it contains escape sequences. It
+// returns -1 for unterminated strings or raw control characters.
+func scanJSONString(data []byte, i int) (int, bool) {
+	escaped := false
+	for i < len(data) {
+		switch c := data[i]; {
+		case c == '"':
+			return i, escaped
+		case c == '\\':
+			escaped = true
+			i += 2
+			continue
+		case c < 0x20:
+			return -1, false
+		}
+		i++
+	}
+	return -1, false
+}
+
+// scanJSONNumber returns the index following a valid JSON number starting at
+// data[i], or -1 when the number is malformed.
+func scanJSONNumber(data []byte, i int) int {
+	digits := func(i int) int {
+		start := i
+		for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return -1
+		}
+		return i
+	}
+	if data[i] == '-' {
+		i++
+	}
+	if i < len(data) && data[i] == '0' {
+		i++
+	} else if i = digits(i); i < 0 {
+		return -1
+	}
+	if i < len(data) && data[i] == '.' {
+		if i = digits(i + 1); i < 0 {
+			return -1
+		}
+	}
+	if i < len(data) && (data[i] == 'e' || data[i] == 'E') {
+		i++
+		if i < len(data) && (data[i] == '+' || data[i] == '-') {
+			i++
+		}
+		if i = digits(i); i < 0 {
+			return -1
+		}
+	}
+	return i
+}
+
+// parseJSONDigits parses a plain, optionally negative, integer without
+// allocating. Other number forms report false.
+func parseJSONDigits(b []byte) (int64, bool) {
+	neg := false
+	if len(b) > 0 && b[0] == '-' {
+		neg, b = true, b[1:]
+	}
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// skipJSONValue returns the index following the JSON value starting at
+// data[i], or -1 when it cannot be delimited.
+func skipJSONValue(data []byte, i int) int {
+	depth := 0
+	for i < len(data) {
+		switch data[i] {
+		case '"':
+			end, _ := scanJSONString(data, i+1)
+			if end < 0 {
+				return -1
+			}
+			i = end + 1
+			if depth == 0 {
+				return i
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i
+			}
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+		i++
+	}
+	return -1
+}
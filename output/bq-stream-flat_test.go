@@ -0,0 +1,275 @@
+package output
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func flatTestEncoder(t *testing.T, discardUnknown bool) (*fastEncoder, *tableDescriptor) {
+	t.Helper()
+	td, err := loadTableDescriptor(&storage.TableSchema{
+		Fields: []*storage.TableFieldSchema{
+			{Name: "s", Type: storage.TableFieldSchema_STRING, Mode: storage.TableFieldSchema_NULLABLE},
+			{Name: "i", Type: storage.TableFieldSchema_INT64, Mode: storage.TableFieldSchema_NULLABLE},
+			{Name: "d", Type: storage.TableFieldSchema_DATE, Mode: storage.TableFieldSchema_NULLABLE},
+			{Name: "f", Type: storage.TableFieldSchema_DOUBLE, Mode: storage.TableFieldSchema_NULLABLE},
+			{Name: "b", Type: storage.TableFieldSchema_BOOL, Mode: storage.TableFieldSchema_NULLABLE},
+			{Name: "by", Type: storage.TableFieldSchema_BYTES, Mode: storage.TableFieldSchema_NULLABLE},
+			{Name: "req", Type: storage.TableFieldSchema_STRING, Mode: storage.TableFieldSchema_REQUIRED},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, ok := newFastEncoder(td.md, discardUnknown, false, service.MockResources().Metrics().NewCounter("discarded"))
+	if !ok || !enc.flat {
+		t.Fatal("expected a flat encoder")
+	}
+	return enc, td
+}
+
+// encodeFlatOrGeneric encodes a row like encodeFast, falling back on the
+// generic encoder when the flat one does not apply.
+func encodeFlatOrGeneric(enc *fastEncoder, data []byte) ([]byte, error) {
+	b, err := enc.encodeFlat(data)
+	if !errors.Is(err, errFlatFallback) {
+		return b, err
+	}
+	row, err := decodeJSONRow(data)
+	if err != nil {
+		return nil, err
+	}
+	return enc.encode(nil, row)
+}
+
+func TestEncodeFlatMatchesProtojson(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		discardUnknown bool
+	}{
+		{name: "scalars", input: `{"req":"r","s":"x","i":42,"d":19000,"f":1.5,"b":true,"by":"AQI="}`},
+		{name: "whitespace", input: " {\n\t\"req\" : \"r\" ,\r\n\"i\" : -7 } "},
+		{name: "escaped string", input: `{"req":"r","s":"a\"b\\c\u00e9\n\t\/"}`},
+		{name: "escaped key", input: `{"req":"r","\u0073":"x"}`},
+		{name: "surrogate pair", input: `{"req":"r","s":"\ud83d\ude00"}`},
+		{name: "unicode", input: `{"req":"r","s":"héllo 世界"}`},
+		{name: "nulls", input: `{"req":"r","s":null,"i":null,"d":null,"f":null,"b":null,"by":null}`},
+		{name: "empty string", input: `{"req":""}`},
+		{name: "int64 max", input: `{"req":"r","i":9223372036854775807}`},
+		{name: "int64 min", input: `{"req":"r","i":-9223372036854775808}`},
+		{name: "int64 overflow", input: `{"req":"r","i":9223372036854775808}`},
+		{name: "int64 underflow", input: `{"req":"r","i":-9223372036854775809}`},
+		{name: "int64 18 digits", input: `{"req":"r","i":999999999999999999}`},
+		{name: "int64 as string", input: `{"req":"r","i":"123"}`},
+		{name: "int64 exponent", input: `{"req":"r","i":1e3}`},
+		{name: "int64 fraction", input: `{"req":"r","i":1.5}`},
+		{name: "int64 negative zero", input: `{"req":"r","i":-0}`},
+		{name: "int32 max", input: `{"req":"r","d":2147483647}`},
+		{name: "int32 min", input: `{"req":"r","d":-2147483648}`},
+		{name: "int32 overflow", input: `{"req":"r","d":2147483648}`},
+		{name: "int32 underflow", input: `{"req":"r","d":-2147483649}`},
+		{name: "double exponent", input: `{"req":"r","f":-1.25E-3}`},
+		{name: "double integer", input: `{"req":"r","f":3}`},
+		{name: "double as string", input: `{"req":"r","f":"2.5"}`},
+		{name: "double special", input: `{"req":"r","f":"NaN"}`},
+		{name: "double overflow", input: `{"req":"r","f":1e400}`},
+		{name: "bool as string", input: `{"req":"r","b":"true"}`},
+		{name: "string as number", input: `{"req":"r","s":1}`},
+		{name: "bytes url encoding", input: `{"req":"r","by":"-_8"}`},
+		{name: "bytes invalid", input: `{"req":"r","by":"!!"}`},
+		{name: "missing required", input: `{"s":"x"}`},
+		{name: "unknown field", input: `{"req":"r","x":{"y":[1,"]"]}}`},
+		{name: "unknown field discarded", input: `{"req":"r","x":{"y":[1,"]"]},"s":"z"}`, discardUnknown: true},
+		{name: "unknown field missing value", input: `{"req":"r","x":}`, discardUnknown: true},
+		{name: "unknown field bad literal", input: `{"req":"r","x":tru}`, discardUnknown: true},
+		{name: "unknown field two values", input: `{"req":"r","x":1 2}`, discardUnknown: true},
+		{name: "unknown field unbalanced", input: `{"req":"r","x":[1}`, discardUnknown: true},
+		{name: "nested value", input: `{"req":"r","s":{"a":1}}`},
+		{name: "duplicate key", input: `{"req":"r","s":"a","s":"b"}`},
+		{name: "trailing data", input: `{"req":"r"}{}`},
+		{name: "trailing garbage", input: `{"req":"r"} x`},
+		{name: "trailing comma", input: `{"req":"r",}`},
+		{name: "missing colon", input: `{"req" "r"}`},
+		{name: "unterminated string", input: `{"req":"r`},
+		{name: "unterminated object", input: `{"req":"r"`},
+		{name: "raw control character", input: "{\"req\":\"a\x01\"}"},
+		{name: "leading zero", input: `{"req":"r","i":01}`},
+		{name: "bare minus", input: `{"req":"r","i":-}`},
+		{name: "bad literal", input: `{"req":"r","b":tru}`},
+		{name: "array", input: `[{"req":"r"}]`},
+		{name: "empty", input: ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enc, td := flatTestEncoder(t, test.discardUnknown)
+
+			want := dynamicpb.NewMessage(td.md)
+			wantErr := protojson.UnmarshalOptions{DiscardUnknown: test.discardUnknown}.Unmarshal([]byte(test.input), want)
+
+			b, err := encodeFlatOrGeneric(enc, []byte(test.input))
+			if (err != nil) != (wantErr != nil) {
+				t.Fatalf("error = %v, protojson error = %v", err, wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := dynamicpb.NewMessage(td.md)
+			if err := proto.Unmarshal(b, got); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestScanJSONString(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantEnd     int
+		wantEscaped bool
+	}{
+		{input: `"abc"`, wantEnd: 4},
+		{input: `""`, wantEnd: 1},
+		{input: `"a\"b"`, wantEnd: 5, wantEscaped: true},
+		{input: `"a\\"`, wantEnd: 4, wantEscaped: true},
+		{input: `"\u00e9"`, wantEnd: 7, wantEscaped: true},
+		{input: `"abc`, wantEnd: -1},
+		{input: `"abc\"`, wantEnd: -1},
+		{input: `"abc\`, wantEnd: -1},
+		{input: "\"a\nb\"", wantEnd: -1},
+	}
+
+	for _, test := range tests {
+		end, escaped := scanJSONString([]byte(test.input), 1)
+		if end != test.wantEnd || escaped != test.wantEscaped {
+			t.Errorf("scanJSONString(%q) = %d, %v, want %d, %v", test.input, end, escaped, test.wantEnd, test.wantEscaped)
+		}
+	}
+}
+
+func TestScanJSONNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{input: `0`, want: 1},
+		{input: `-0`, want: 2},
+		{input: `123,`, want: 3},
+		{input: `-1.5}`, want: 4},
+		{input: `1e10`, want: 4},
+		{input: `1E+10`, want: 5},
+		{input: `2.5e-3 `, want: 6},
+		{input: `01`, want: 1},
+		{input: `-`, want: -1},
+		{input: `1.`, want: -1},
+		{input: `1.e5`, want: -1},
+		{input: `1e`, want: -1},
+		{input: `1e+`, want: -1},
+		{input: `-a`, want: -1},
+	}
+
+	for _, test := range tests {
+		if got := scanJSONNumber([]byte(test.input), 0); got != test.want {
+			t.Errorf("scanJSONNumber(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseJSONDigits(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   int64
+		wantOK bool
+	}{
+		{input: `0`, want: 0, wantOK: true},
+		{input: `-0`, want: 0, wantOK: true},
+		{input: `42`, want: 42, wantOK: true},
+		{input: `-2147483649`, want: -2147483649, wantOK: true},
+		{input: `999999999999999999`, want: 999999999999999999, wantOK: true},
+		{input: `-999999999999999999`, want: -999999999999999999, wantOK: true},
+		{input: `9223372036854775807`},
+		{input: `1.5`},
+		{input: `1e3`},
+		{input: `-`},
+		{input: ``},
+	}
+
+	for _, test := range tests {
+		got, ok := parseJSONDigits([]byte(test.input))
+		if ok != test.wantOK || (ok && got != test.want) {
+			t.Errorf("parseJSONDigits(%q) = %d, %v, want %d, %v", test.input, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestSkipJSONValue(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{input: `"a,b"`, want: 5},
+		{input: `"a\"}"}`, want: 6},
+		{input: `123,"x"`, want: 3},
+		{input: `null}`, want: 4},
+		{input: `{"a":[1,{"b":"}"}]},`, want: 19},
+		{input: `[1,[2,3]]}`, want: 9},
+		{input: `{"a":1`, want: -1},
+		{input: `"abc`, want: -1},
+	}
+
+	for _, test := range tests {
+		if got := skipJSONValue([]byte(test.input), 0); got != test.want {
+			t.Errorf("skipJSONValue(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
+
+func TestEncodeDecimalString(t *testing.T) {
+	tests := []struct {
+		input   string
+		scale   int
+		want    string
+		wantErr bool
+	}{
+		{input: "0", scale: 0, want: "AA=="},
+		{input: "1", scale: 0, want: "AQ=="},
+		{input: "-1", scale: 0, want: "/w=="},
+		{input: "127", scale: 0, want: "fw=="},
+		{input: "128", scale: 0, want: "gAA="},
+		{input: "-128", scale: 0, want: "gP8="},
+		{input: "1.23", scale: 2, want: "ew=="},
+		{input: "0.5", scale: 0, want: "AQ=="},
+		{input: "-0.5", scale: 0, want: "/w=="},
+		{input: "0.49", scale: 0, want: "AA=="},
+		{input: "1e2", scale: 0, want: "ZA=="},
+		{input: "abc", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := encodeDecimalString(test.input, test.scale)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("encodeDecimalString(%q) = %q, expected an error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("encodeDecimalString(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("encodeDecimalString(%q, %d) = %q, want %q", test.input, test.scale, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errMessageTooLarge = errors.New("message exceeds maximum size")
+	errJSONTooDeep     = errors.New("message exceeds maximum JSON nesting depth")
+)
+
+// checkMessageGuards rejects pathological payloads before any conversion work
+// is done, a limit of zero disables the respective check.
+func checkMessageGuards(msgBytes []byte, maxBytes, maxDepth int) error {
+	if maxBytes > 0 && len(msgBytes) > maxBytes {
+		return fmt.Errorf("%w: %d bytes, limit %d", errMessageTooLarge, len(msgBytes), maxBytes)
+	}
+	if maxDepth > 0 {
+		if depth, exceeded := jsonDepthExceeds(msgBytes, maxDepth); exceeded {
+			return fmt.Errorf("%w: reached %d, limit %d", errJSONTooDeep, depth, maxDepth)
+		}
+	}
+	return nil
+}
+
+// jsonDepthExceeds scans the raw JSON bytes and reports whether objects and
+// arrays nest deeper than limit, stopping as soon as they do. It does not
+// validate the document, that is left to the decoder.
+func jsonDepthExceeds(b []byte, limit int) (int, bool) {
+	depth := 0
+	inString := false
+	escaped := false
+	for _, c := range b {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > limit {
+				return depth, true
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return depth, false
+}
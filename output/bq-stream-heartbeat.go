@@ -0,0 +1,55 @@
+package output
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// throughputCounters accumulate the totals reported by the heartbeat.
+type throughputCounters struct {
+	messages atomic.Int64
+	appended atomic.Int64
+	bytes    atomic.Int64
+	failed   atomic.Int64
+
+	// inFlight counts batches being written.
+	inFlight atomic.Int64
+}
+
+// heartbeat logs the throughput of the output every interval, as a health
+// signal for hosts without a metrics stack.
+func (g *gcpBigQueryOutput) heartbeat(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	last := time.Now()
+	var messages, appended, bytes, failed int64
+	for {
+		select {
+		case <-t.C:
+		case <-g.shutdownCtx.Done():
+			return
+		}
+		now := time.Now()
+		elapsed := now.Sub(last).Seconds()
+		last = now
+
+		m, a, b, f := g.throughput.messages.Load(), g.throughput.appended.Load(), g.throughput.bytes.Load(), g.throughput.failed.Load()
+		var errorRate float64
+		if m > messages {
+			errorRate = float64(f-failed) / float64(m-messages)
+		}
+		pending := 0
+		if g.collector != nil {
+			pending = len(g.collector.queue)
+		}
+		g.log.With(
+			"destination", g.destination(),
+			"rows_per_sec", float64(a-appended)/elapsed,
+			"bytes_per_sec", float64(b-bytes)/elapsed,
+			"error_rate", errorRate,
+			"batches_in_flight", g.throughput.inFlight.Load(),
+			"appends_pending", pending,
+		).Info("heartbeat")
+		messages, appended, bytes, failed = m, a, b, f
+	}
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// requiredPermissions returns the IAM permissions the output needs on the
+// destination table.
+func (g *gcpBigQueryOutput) requiredPermissions() []string {
+	perms := []string{"bigquery.tables.updateData"}
+	if !g.conf.SkipExistenceCheck {
+		perms = append(perms, "bigquery.tables.get")
+	}
+	return perms
+}
+
+// checkPermissions verifies that the identity of the clients holds the
+// permissions the output needs on the destination table, so that missing
+// grants are reported on connect rather than as PERMISSION_DENIED on the
+// first append.
+func (g *gcpBigQueryOutput) checkPermissions(ctx context.Context, client *bigquery.Client) error {
+	required := g.requiredPermissions()
+	table := client.DatasetInProject(g.conf.ProjectID, g.conf.DatasetID).Table(g.conf.TableID)
+	granted, err := table.IAM().TestPermissions(ctx, required)
+	if err != nil {
+		return fmt.Errorf("error checking permissions on table %s.%s.%s: %w", g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID, err)
+	}
+	var missing []string
+	for _, p := range required {
+		if !slices.Contains(granted, p) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("credentials lack %s on table %s.%s.%s, grant roles/bigquery.dataEditor on the table or dataset", strings.Join(missing, ", "), g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID)
+	}
+	return nil
+}
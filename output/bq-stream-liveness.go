@@ -0,0 +1,68 @@
+package output
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+)
+
+const livenessProbeTimeout = 30 * time.Second
+
+// probeLiveness checks the managed stream every interval in which nothing was
+// appended, so that a stream that died while idle is replaced before the next
+// batch arrives rather than failing it.
+func (g *gcpBigQueryOutput) probeLiveness(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-g.shutdownCtx.Done():
+			return
+		}
+		if time.Since(time.Unix(0, g.lastAppend.Load())) < interval {
+			continue
+		}
+		g.probeStream(g.shutdownCtx)
+	}
+}
+
+// probeStream reads the state of the managed stream and replaces the stream
+// when that fails in a way a reconnect or resync recovers from.
+func (g *gcpBigQueryOutput) probeStream(ctx context.Context) {
+	g.connMut.RLock()
+	ms, mwClient := g.managedStream, g.mwClient
+	g.connMut.RUnlock()
+	if ms == nil || mwClient == nil {
+		return
+	}
+
+	// Only the probe itself is time bound, the stream replacing a dead one
+	// must outlive this call.
+	probeCtx, cancel := context.WithTimeout(ctx, livenessProbeTimeout)
+	_, err := mwClient.GetWriteStream(probeCtx, &storage.GetWriteStreamRequest{
+		Name: ms.StreamName(),
+		View: storage.WriteStreamView_BASIC,
+	})
+	cancel()
+	if err == nil || g.shutdownCtx.Err() != nil {
+		return
+	}
+
+	switch {
+	case isTableReplacedError(err):
+		_ = g.resyncAfter(ctx, ms, err)
+	case g.errorClass(err) == errorReconnectable:
+		g.log.Warnf("liveness probe of BigQuery managed stream failed, reconnecting: %v", err)
+		reconnect := g.reconnect
+		if isClientError(err) {
+			reconnect = g.recreateClients
+		}
+		if reconnectErr := reconnect(ctx, ms); reconnectErr != nil {
+			g.log.Errorf("failed to reconnect BigQuery stream: %v", reconnectErr)
+		}
+	default:
+		g.log.Warnf("liveness probe of BigQuery managed stream failed: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// logSampler limits repetitive row error logs. Within each interval the first
+// lines of a class of error are logged, and after that only every
+// thereafter-th line, carrying the number of lines suppressed since the last
+// one logged. A nil sampler logs every line.
+type logSampler struct {
+	first      int
+	thereafter int
+	interval   time.Duration
+
+	mu      sync.Mutex
+	started time.Time
+	classes map[string]*sampledClass
+}
+
+type sampledClass struct {
+	seen       int
+	suppressed int
+}
+
+func newLogSampler(first, thereafter int, interval time.Duration) *logSampler {
+	if first <= 0 {
+		return nil
+	}
+	return &logSampler{
+		first:      first,
+		thereafter: thereafter,
+		interval:   interval,
+		classes:    map[string]*sampledClass{},
+	}
+}
+
+// allow reports whether a line of the class key should be logged, and the
+// number of lines of the class suppressed since the last one logged.
+func (s *logSampler) allow(key string) (bool, int) {
+	if s == nil {
+		return true, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.Sub(s.started) >= s.interval {
+		s.started = now
+		clear(s.classes)
+	}
+	c, ok := s.classes[key]
+	if !ok {
+		c = &sampledClass{}
+		s.classes[key] = c
+	}
+	c.seen++
+	if c.seen <= s.first || (s.thereafter > 0 && (c.seen-s.first)%s.thereafter == 0) {
+		suppressed := c.suppressed
+		c.suppressed = 0
+		return true, suppressed
+	}
+	c.suppressed++
+	return false, 0
+}
+
+// logRowError logs a row error through logf unless error_log_sampling
+// suppresses it, where key identifies lines that repeat the same error.
+func (g *gcpBigQueryOutput) logRowError(logf func(string, ...any), key, format string, args ...any) {
+	ok, suppressed := g.logSampler.allow(key)
+	if !ok {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if suppressed > 0 {
+		msg = fmt.Sprintf("%s (%d similar lines suppressed)", msg, suppressed)
+	}
+	logf("%s", msg)
+}
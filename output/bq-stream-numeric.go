@@ -0,0 +1,81 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+)
+
+const (
+	numericScale    = 9
+	bigNumericScale = 38
+)
+
+// commaDecimalPattern matches numbers with '.' grouping digits in threes and
+// ',' as the decimal separator, e.g. "-1.234,56", "1234,5" or "12".
+var commaDecimalPattern = regexp.MustCompile(`^[+-]?(\d{1,3}(\.\d{3})+|\d+)(,\d+)?$`)
+
+// normalizeCommaDecimal rewrites a number formatted with '.' as the grouping
+// separator and ',' as the decimal separator (e.g. "1.234,56") into the
+// canonical "1234.56" form. Strings in any other format, including period
+// decimals such as "12.5", are rejected rather than misread.
+func normalizeCommaDecimal(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !commaDecimalPattern.MatchString(s) {
+		return "", fmt.Errorf("unable to parse %q as a comma decimal number", s)
+	}
+	s = strings.ReplaceAll(strings.TrimPrefix(s, "+"), ".", "")
+	return strings.Replace(s, ",", ".", 1), nil
+}
+
+// parseCommaDecimal converts a comma-decimal string written to a numeric
+// column into the JSON form expected by its proto field.
+func parseCommaDecimal(s string, typ storage.TableFieldSchema_Type) (any, error) {
+	canonical, err := normalizeCommaDecimal(s)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case storage.TableFieldSchema_NUMERIC:
+		return encodeDecimalString(canonical, numericScale)
+	case storage.TableFieldSchema_BIGNUMERIC:
+		return encodeDecimalString(canonical, bigNumericScale)
+	}
+	if _, ok := new(big.Rat).SetString(canonical); !ok {
+		return nil, fmt.Errorf("unable to parse %q as a number", s)
+	}
+	return json.Number(canonical), nil
+}
+
+// encodeDecimalString encodes a decimal string as the little-endian two's
+// complement integer of the value scaled by 10^scale, which is the bytes
+// representation of NUMERIC and BIGNUMERIC accepted by the Write API. The
+// result is base64 encoded as required for bytes fields in proto JSON.
+func encodeDecimalString(s string, scale int) (string, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", fmt.Errorf("unable to parse %q as a decimal", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+
+	// Round half away from zero, matching BigQuery coercion of extra digits.
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Lsh(new(big.Int).Abs(rem), 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+
+	n := q.BitLen()/8 + 1
+	if q.Sign() < 0 {
+		q.Add(q, new(big.Int).Lsh(big.NewInt(1), uint(8*n)))
+	}
+	b := q.FillBytes(make([]byte, n))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,42 @@
+package output
+
+import "testing"
+
+func TestNormalizeCommaDecimal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "1.234,56", want: "1234.56"},
+		{input: "-1.234.567,5", want: "-1234567.5"},
+		{input: " 12,5 ", want: "12.5"},
+		{input: "1234,5", want: "1234.5"},
+		{input: "12", want: "12"},
+		{input: "+1.000", want: "1000"},
+		{input: "12.5", wantErr: true},
+		{input: "1.23,4", wantErr: true},
+		{input: "1,2,3", wantErr: true},
+		{input: "1.234.56", wantErr: true},
+		{input: ",5", wantErr: true},
+		{input: "1e3", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := normalizeCommaDecimal(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("normalizeCommaDecimal(%q) = %q, expected an error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("normalizeCommaDecimal(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("normalizeCommaDecimal(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
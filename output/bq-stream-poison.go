@@ -0,0 +1,46 @@
+package output
+
+import (
+	"context"
+)
+
+// rejectFatalChunk handles an append that failed with a fatal error. Without
+// poison row isolation every row of the append is rejected. With it, the
+// rows are bisected and appended again until the rows causing the error are
+// isolated, so that only those are rejected. It returns the errors of rows
+// that were not written keyed by their position in rows, and an error when
+// an append failed in a way that is not attributable to the rows.
+func (g *gcpBigQueryOutput) rejectFatalChunk(ctx context.Context, rows [][]byte, err error) (map[int]error, error) {
+	if !g.conf.IsolatePoisonRows {
+		rejected := make(map[int]error, len(rows))
+		for pos := range rows {
+			rejected[pos] = &rowRejectedError{err: err}
+		}
+		return rejected, nil
+	}
+	return g.isolatePoisonRows(ctx, rows, err)
+}
+
+func (g *gcpBigQueryOutput) isolatePoisonRows(ctx context.Context, rows [][]byte, err error) (map[int]error, error) {
+	if len(rows) == 1 {
+		return map[int]error{0: &rowRejectedError{err: err}}, nil
+	}
+	g.log.Debugf("bisecting %d rows to isolate rows failing with: %v", len(rows), err)
+
+	failed := map[int]error{}
+	mid := len(rows) / 2
+	for _, half := range [][2]int{{0, mid}, {mid, len(rows)}} {
+		part := rows[half[0]:half[1]]
+		partFailed, partErr := g.resolveRowErrors(ctx, part, g.appendWithRetry(ctx, part, 0))
+		if g.errorClass(partErr) == errorFatal {
+			partFailed, partErr = g.isolatePoisonRows(ctx, part, partErr)
+		}
+		if partErr != nil {
+			return nil, partErr
+		}
+		for pos, rowErr := range partFailed {
+			failed[half[0]+pos] = rowErr
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxScratchBytes bounds the buffers kept in scratchPool, so that a single
+// large nested message does not pin its buffer for the life of the pool.
+const maxScratchBytes = 64 * 1024
+
+// messagePool recycles dynamic messages of a single descriptor across rows
+// so that conversion does not allocate a fresh message per row.
+type messagePool struct {
+	md   protoreflect.MessageDescriptor
+	pool sync.Pool
+}
+
+func newMessagePool(md protoreflect.MessageDescriptor) *messagePool {
+	p := &messagePool{md: md}
+	p.pool.New = func() any {
+		return dynamicpb.NewMessage(md)
+	}
+	return p
+}
+
+func (p *messagePool) get() *dynamicpb.Message {
+	return p.pool.Get().(*dynamicpb.Message)
+}
+
+// put clears m in place and returns it to the pool. Unlike proto.Reset,
+// which allocates a replacement message, clearing reuses the existing one.
+func (p *messagePool) put(m *dynamicpb.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		m.Clear(fd)
+		return true
+	})
+	m.SetUnknown(nil)
+	p.pool.Put(m)
+}
+
+// scratchPool holds intermediate buffers used while encoding nested
+// messages, the encoded bytes are copied out before a buffer is returned.
+var scratchPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// putScratch returns a buffer to scratchPool unless it has grown beyond
+// maxScratchBytes.
+func putScratch(b *[]byte) {
+	if cap(*b) > maxScratchBytes {
+		return
+	}
+	scratchPool.Put(b)
+}
@@ -0,0 +1,104 @@
+package output
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isQuotaError reports whether an append failed because a quota such as the
+// append throughput quota was exceeded.
+func isQuotaError(err error) bool {
+	if s, ok := status.FromError(err); ok && s.Code() == codes.ResourceExhausted {
+		return true
+	}
+	if apiErr, ok := apierror.FromError(err); ok && apiErr.GRPCStatus().Code() == codes.ResourceExhausted {
+		return true
+	}
+	return false
+}
+
+// quotaDimension names the quota that an error exceeded: "throughput",
+// "concurrent_connections", "request_size" or "other".
+func quotaDimension(err error) string {
+	msg := strings.ToLower(err.Error())
+	if apiErr, ok := apierror.FromError(err); ok {
+		for _, v := range apiErr.Details().QuotaFailure.GetViolations() {
+			msg += " " + strings.ToLower(v.GetSubject()+" "+v.GetDescription())
+		}
+	}
+	switch {
+	case strings.Contains(msg, "concurrent"):
+		return "concurrent_connections"
+	case strings.Contains(msg, "throughput"):
+		return "throughput"
+	case strings.Contains(msg, "too large"), strings.Contains(msg, "request size"), strings.Contains(msg, "message size"):
+		return "request_size"
+	}
+	return "other"
+}
+
+// isRequestSizeError reports whether an append was rejected for exceeding
+// the maximum AppendRows request size, which is reported as an invalid
+// argument rather than a quota error.
+func isRequestSizeError(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.InvalidArgument && quotaDimension(err) == "request_size"
+}
+
+// reportQuota records and logs an append error caused by an exceeded quota,
+// so that throttling is not mistaken for a generic append failure.
+func (g *gcpBigQueryOutput) reportQuota(err error) {
+	if !isQuotaError(err) && !isRequestSizeError(err) {
+		return
+	}
+	dim := quotaDimension(err)
+	g.mQuotaExceeded.Incr(1, dim)
+	switch dim {
+	case "throughput":
+		g.log.Warnf("bigquery append throughput quota exceeded for table %v, consider requesting a higher quota or reducing the write rate: %v", g.conf.TableID, err)
+	case "concurrent_connections":
+		g.log.Warnf("bigquery concurrent connections quota exceeded for table %v, consider reducing the number of outputs or connection_pool_size: %v", g.conf.TableID, err)
+	case "request_size":
+		g.log.Warnf("bigquery append request size limit exceeded for table %v, consider lowering max_bytes_per_append: %v", g.conf.TableID, err)
+	default:
+		g.log.Warnf("bigquery quota exceeded for table %v: %v", g.conf.TableID, err)
+	}
+}
+
+// appendRetryDelay returns the delay requested by the RetryInfo details of a
+// retryable error. Without one, quota errors are waited out with the quota
+// backoff and other retryable errors with the retry policy.
+func (g *gcpBigQueryOutput) appendRetryDelay(err error, retries int) time.Duration {
+	if apiErr, ok := apierror.FromError(err); ok {
+		if d := apiErr.Details().RetryInfo.GetRetryDelay(); d != nil && d.AsDuration() > 0 {
+			return d.AsDuration()
+		}
+	}
+	if isQuotaError(err) {
+		return g.conf.QuotaBackoff.backoff(retries)
+	}
+	return g.conf.Retry.backoff(retries)
+}
+
+// retryQuotaAppend waits out a quota or other retryable error with its own
+// backoff and appends the rows again. The stream itself is healthy, so it is
+// not reconnected.
+func (g *gcpBigQueryOutput) retryQuotaAppend(ctx context.Context, rows [][]byte, retryCount int, started time.Time, err error) error {
+	g.recordRetry(ctx)
+	delay := g.appendRetryDelay(err, retryCount)
+	if isQuotaError(err) {
+		g.log.Infof("retrying append after quota error in %v (attempt %d/%d)", delay, retryCount+1, g.conf.Retry.maxAttempts-1)
+	} else {
+		g.log.Warnf("bigquery append failed, retrying in %v (attempt %d/%d): %v", delay, retryCount+1, g.conf.Retry.maxAttempts-1, err)
+	}
+
+	if err := sleep(ctx, delay); err != nil {
+		return err
+	}
+	return g.appendAttempt(ctx, rows, retryCount+1, started)
+}
@@ -0,0 +1,33 @@
+package output
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAppendRetryDelay(t *testing.T) {
+	g := &gcpBigQueryOutput{conf: gcpBigQueryOutputConfig{
+		Retry:        retryPolicy{initialBackoff: time.Second},
+		QuotaBackoff: retryPolicy{initialBackoff: 5 * time.Second},
+	}}
+
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{name: "quota", err: status.Error(codes.ResourceExhausted, "quota"), want: 5 * time.Second},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "unavailable"), want: time.Second},
+		{name: "plain", err: errors.New("failed"), want: time.Second},
+	}
+
+	for _, test := range tests {
+		if got := g.appendRetryDelay(test.err, 0); got != test.want {
+			t.Errorf("%s: appendRetryDelay = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
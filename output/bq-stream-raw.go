@@ -0,0 +1,43 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+)
+
+// rawJSONRow wraps the entire message body into a row that writes it to the
+// configured raw_json_column, alongside any configured metadata columns.
+func (g *gcpBigQueryOutput) rawJSONRow(msg *service.Message, msgBytes []byte) ([]byte, error) {
+	row := make(map[string]any, len(g.conf.RawJSONMetadataColumns)+1)
+	row[g.conf.RawJSONColumn] = string(msgBytes)
+	for column, key := range g.conf.RawJSONMetadataColumns {
+		if v, ok := msg.MetaGet(key); ok {
+			row[column] = v
+		}
+	}
+	return json.Marshal(row)
+}
+
+// checkRawJSONColumns verifies that the raw mode columns exist in the table
+// schema and can hold string values.
+func checkRawJSONColumns(conf gcpBigQueryOutputConfig, fields map[string]*schemaField) error {
+	columns := []string{conf.RawJSONColumn}
+	for column := range conf.RawJSONMetadataColumns {
+		columns = append(columns, column)
+	}
+	for _, column := range columns {
+		f, ok := fields[column]
+		if !ok {
+			return fmt.Errorf("raw mode column %v does not exist in table %v", column, conf.TableID)
+		}
+		switch f.schema.GetType() {
+		case storage.TableFieldSchema_JSON, storage.TableFieldSchema_STRING:
+		default:
+			return fmt.Errorf("raw mode column %v must be of type JSON or STRING, got %v", column, f.schema.GetType())
+		}
+	}
+	return nil
+}
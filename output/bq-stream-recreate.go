@@ -0,0 +1,53 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"google.golang.org/grpc/codes"
+)
+
+// isClientError reports whether an append failed because of the clients
+// rather than the stream, such as expired credentials or a closed gRPC
+// channel. Recreating the stream on the same clients keeps failing then.
+func isClientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if s := errorStatus(err); s != nil && s.Code() == codes.Unauthenticated {
+		return true
+	}
+	errStr := strings.ToLower(err.Error())
+	for _, pattern := range []string{"oauth2:", "client connection is closing", "invalid_grant"} {
+		if strings.Contains(errStr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// recreateClients replaces the BigQuery and managed writer clients along with
+// the failed managed stream, for when the clients themselves are broken.
+func (g *gcpBigQueryOutput) recreateClients(ctx context.Context, failed *managedwriter.ManagedStream) error {
+	return g.replaceStream(ctx, failed, false, true)
+}
+
+// newClients creates a BigQuery client and a managed writer client.
+// newClients creates the BigQuery and managed writer clients. The managed
+// writer client keeps ctx for its lifetime, so it must not be the context of
+// a single batch.
+func (g *gcpBigQueryOutput) newClients(ctx context.Context) (*bigquery.Client, *managedwriter.Client, error) {
+	client, err := g.clientURL.NewClient(ctx, g.conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating big query client: %w", err)
+	}
+	mwClient, err := g.mwClientURL.NewClient(ctx, g.conf)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("error creating BigQuery managed writer client: %w", err)
+	}
+	return client, mwClient, nil
+}
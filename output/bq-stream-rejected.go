@@ -0,0 +1,58 @@
+package output
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/grpc/codes"
+)
+
+// rejectionCategory names the cause of a rejected row for the
+// bigquery_stream_rows_rejected metric: "conversion", "row_too_large",
+// "invalid_argument", "quota" or "unknown".
+func rejectionCategory(err error) string {
+	var rejected *rowRejectedError
+	isRejected := errors.As(err, &rejected)
+	s := errorStatus(err)
+	switch {
+	case errors.Is(err, errRowTooLarge), errors.Is(err, errMessageTooLarge):
+		return "row_too_large"
+	case isQuotaError(err):
+		return "quota"
+	case isRejected && rejected.storage, s != nil && s.Code() == codes.InvalidArgument:
+		return "invalid_argument"
+	case isRejected && s == nil:
+		return "conversion"
+	}
+	return "unknown"
+}
+
+// countRejected counts the messages of a batch failed by err by the category
+// of their error. Messages that were not written because the output was not
+// connected or shutting down are not rejected and are not counted.
+func (g *gcpBigQueryOutput) countRejected(batch service.MessageBatch, err error) {
+	if err == nil || errors.Is(err, service.ErrNotConnected) || errors.Is(err, context.Canceled) {
+		return
+	}
+	var batchErr *service.BatchError
+	if !errors.As(err, &batchErr) {
+		g.mRowsRejected.Incr(int64(len(batch)), rejectionCategory(err))
+		g.throughput.failed.Add(int64(len(batch)))
+		return
+	}
+	batchErr.WalkMessagesIndexedBy(batch.Index(), func(_ int, _ *service.Message, err error) bool {
+		if err != nil {
+			g.mRowsRejected.Incr(1, rejectionCategory(err))
+			g.throughput.failed.Add(1)
+		}
+		return true
+	})
+}
+
+// isPermanentFailure reports whether a batch failed with errors that retrying
+// does not fix, such as rows rejected by the conversion or by BigQuery.
+func (g *gcpBigQueryOutput) isPermanentFailure(err error) bool {
+	var rejected *rowRejectedError
+	return errors.As(err, &rejected) || g.errorClass(err) == errorFatal
+}
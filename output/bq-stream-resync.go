@@ -0,0 +1,69 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// isTableReplacedError reports whether an append failed because the
+// destination table was deleted, or deleted and recreated, while the stream
+// was open. Retrying on the same stream keeps failing in that case.
+func isTableReplacedError(err error) bool {
+	s := errorStatus(err)
+	if s == nil {
+		return false
+	}
+	for _, d := range s.Details() {
+		if se, ok := d.(*storage.StorageError); ok && se.GetCode() == storage.StorageError_TABLE_NOT_FOUND {
+			return true
+		}
+	}
+	if s.Code() == codes.NotFound {
+		return true
+	}
+	msg := strings.ToLower(s.Message())
+	return strings.Contains(msg, "was recreated") || strings.Contains(msg, "was deleted")
+}
+
+// resyncAfter reloads the table schema and recreates the stream after the
+// table was replaced. The rows were converted for the old schema, so the
+// original error is returned for the batch to be retried and converted again.
+func (g *gcpBigQueryOutput) resyncAfter(ctx context.Context, ms *managedwriter.ManagedStream, err error) error {
+	g.log.Warnf("bigquery table %v was deleted or recreated, reloading its schema: %v", g.conf.TableID, err)
+	if resyncErr := g.resync(ctx, ms); resyncErr != nil {
+		g.log.Errorf("failed to resync BigQuery table: %v", resyncErr)
+		return fmt.Errorf("table resync failed: %w", resyncErr)
+	}
+	return err
+}
+
+// refreshTableDescriptor reads the table schema from BigQuery, bypassing and
+// invalidating any cached copy.
+func (g *gcpBigQueryOutput) refreshTableDescriptor(ctx context.Context, client *bigquery.Client, mwClient *managedwriter.Client) (*tableDescriptor, error) {
+	if g.conf.SchemaCache != "" {
+		if err := g.mgr.AccessCache(ctx, g.conf.SchemaCache, func(c service.Cache) {
+			_ = c.Delete(ctx, g.schemaCacheKey())
+		}); err != nil {
+			g.log.Warnf("unable to invalidate cached schema of table %v: %v", g.conf.TableID, err)
+		}
+	}
+	ts, err := g.tableSchema(ctx, client, mwClient)
+	if err != nil {
+		return nil, err
+	}
+	g.mSchemaRefreshes.Incr(1)
+	g.emit(eventSchemaRefresh, nil, 0)
+	td, err := loadTableDescriptor(ts)
+	if err != nil {
+		return nil, err
+	}
+	g.dumpDescriptor(ts, td)
+	return td, nil
+}
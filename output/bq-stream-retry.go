@@ -0,0 +1,62 @@
+package output
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// retryPolicy controls how appends failing with connection errors are retried
+// after reconnecting the stream.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	jitter         float64
+	maxElapsed     time.Duration
+}
+
+// allows reports whether another retry may follow retries previous retries
+// of an append first attempted at started.
+func (p retryPolicy) allows(retries int, started time.Time) bool {
+	if retries+1 >= p.maxAttempts {
+		return false
+	}
+	return p.maxElapsed <= 0 || time.Since(started)+p.backoff(retries) < p.maxElapsed
+}
+
+// backoff returns the delay before retry number retries+1, doubling from the
+// initial backoff up to the maximum and randomised by the jitter fraction.
+func (p retryPolicy) backoff(retries int) time.Duration {
+	d := p.initialBackoff
+	for i := 0; i < retries && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if p.maxBackoff > 0 {
+		d = min(d, p.maxBackoff)
+	}
+	if p.jitter > 0 {
+		d += time.Duration(float64(d) * p.jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+func (p retryPolicy) wait(ctx context.Context, retries int) error {
+	return sleep(ctx, p.backoff(retries))
+}
+
+// sleep waits for d, returning early with the error of ctx when it is done
+// first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+)
+
+// appendRowErrors is returned when BigQuery rejected an append because of
+// errors in specific rows. No rows of such an append are written.
+type appendRowErrors struct {
+	err  error
+	rows map[int]error
+}
+
+func (e *appendRowErrors) Error() string {
+	return fmt.Sprintf("%d rows rejected: %v", len(e.rows), e.err)
+}
+
+func (e *appendRowErrors) Unwrap() error {
+	return e.err
+}
+
+// rowRejectedError marks errors of rows that can never be written as they
+// are, such as rows failing conversion or rejected by BigQuery, as opposed to
+// rows that failed along with their whole append.
+type rowRejectedError struct {
+	err error
+
+	// storage is set for rows rejected by the Storage Write API in the
+	// response of an append.
+	storage bool
+}
+
+func (e *rowRejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rowRejectedError) Unwrap() error {
+	return e.err
+}
+
+// withRowErrors wraps the error of a failed append in appendRowErrors when the
+// append response reports errors for individual rows.
+func withRowErrors(ctx context.Context, result *managedwriter.AppendResult, err error) error {
+	resp, _ := result.FullResponse(ctx)
+	rowErrs := resp.GetRowErrors()
+	if len(rowErrs) == 0 {
+		return err
+	}
+	rows := make(map[int]error, len(rowErrs))
+	for _, re := range rowErrs {
+		rows[int(re.GetIndex())] = &rowRejectedError{err: fmt.Errorf("row rejected (%v): %v", re.GetCode(), re.GetMessage()), storage: true}
+	}
+	return &appendRowErrors{err: err, rows: rows}
+}
+
+// resolveRowErrors handles an append that failed with row errors by appending
+// the remaining rows again without the rejected ones. It returns the errors of
+// rows that were not written keyed by their position in rows, and an error
+// when the append failed as a whole without row errors.
+func (g *gcpBigQueryOutput) resolveRowErrors(ctx context.Context, rows [][]byte, err error) (map[int]error, error) {
+	var rowErrs *appendRowErrors
+	if !errors.As(err, &rowErrs) {
+		return nil, err
+	}
+	failed := rowErrs.rows
+
+	var remaining [][]byte
+	var positions []int
+	for i, row := range rows {
+		if _, bad := failed[i]; !bad {
+			remaining = append(remaining, row)
+			positions = append(positions, i)
+		}
+	}
+	g.log.Debugf("%d of %d rows rejected by BigQuery, appending the remaining rows again", len(failed), len(rows))
+	if len(remaining) == 0 {
+		return failed, nil
+	}
+	if err := g.appendWithRetry(ctx, remaining, 0); err != nil {
+		for _, p := range positions {
+			failed[p] = err
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// defaultMaxRowBytes is the largest row that fits in a single append,
+	// which holds maxAppendBytes of row data including the tag and length
+	// prefix of each row.
+	defaultMaxRowBytes = maxAppendBytes - 8
+
+	rowSizeActionReject   = "reject"
+	rowSizeActionDrop     = "drop"
+	rowSizeActionTruncate = "truncate"
+)
+
+var (
+	errRowTooLarge = errors.New("row exceeds maximum size")
+	errRowDropped  = errors.New("row dropped")
+)
+
+// handleOversizedRow applies the configured row_size_action to a serialized
+// row that exceeds max_row_bytes.
+func (g *gcpBigQueryOutput) handleOversizedRow(message *dynamicpb.Message, b []byte) ([]byte, error) {
+	switch g.conf.RowSizeAction {
+	case rowSizeActionDrop:
+		g.logRowError(g.log.Warnf, "row_dropped", "dropping row of %d bytes exceeding max_row_bytes %d", len(b), g.conf.MaxRowBytes)
+		return nil, errRowDropped
+	case rowSizeActionTruncate:
+		return g.truncateRow(message, b)
+	}
+	return nil, fmt.Errorf("%w: %d bytes, limit %d", errRowTooLarge, len(b), g.conf.MaxRowBytes)
+}
+
+// truncateRow shortens the configured truncate_columns in order until the
+// serialized row fits within max_row_bytes.
+func (g *gcpBigQueryOutput) truncateRow(message *dynamicpb.Message, b []byte) ([]byte, error) {
+	originalSize := len(b)
+	fields := message.Descriptor().Fields()
+	for _, col := range g.conf.TruncateColumns {
+		fd := fields.ByName(protoreflect.Name(col))
+		if fd == nil || fd.IsList() || !message.Has(fd) {
+			continue
+		}
+
+		overflow := len(b) - g.conf.MaxRowBytes
+		switch fd.Kind() {
+		case protoreflect.StringKind:
+			str := message.Get(fd).String()
+			message.Set(fd, protoreflect.ValueOfString(truncateString(str, len(str)-overflow)))
+		case protoreflect.BytesKind:
+			bs := message.Get(fd).Bytes()
+			message.Set(fd, protoreflect.ValueOfBytes(bs[:max(len(bs)-overflow, 0)]))
+		default:
+			continue
+		}
+
+		var err error
+		if b, err = g.mo.Marshal(message); err != nil {
+			return nil, err
+		}
+		if len(b) <= g.conf.MaxRowBytes {
+			g.log.Debugf("truncated row from %d to %d bytes", originalSize, len(b))
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d bytes after truncation, limit %d", errRowTooLarge, len(b), g.conf.MaxRowBytes)
+}
+
+// truncateString cuts s to at most n bytes without splitting a UTF-8 rune.
+func truncateString(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
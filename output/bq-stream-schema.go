@@ -0,0 +1,121 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// tableDescriptor holds the artifacts derived from a table schema, which are
+// immutable and therefore shared by every output writing to a table with the
+// same schema.
+type tableDescriptor struct {
+	md     protoreflect.MessageDescriptor
+	dp     *descriptorpb.DescriptorProto
+	fields map[string]*schemaField
+}
+
+// sharedDescriptors maps deterministically serialized table schemas to their
+// tableDescriptor.
+var sharedDescriptors sync.Map
+
+func loadTableDescriptor(ts *storage.TableSchema) (*tableDescriptor, error) {
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := sharedDescriptors.Load(string(key)); ok {
+		return v.(*tableDescriptor), nil
+	}
+
+	md, dp, err := getDescriptor(ts)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := sharedDescriptors.LoadOrStore(string(key), &tableDescriptor{
+		md:     md,
+		dp:     dp,
+		fields: newSchemaFields(ts.GetFields()),
+	})
+	return v.(*tableDescriptor), nil
+}
+
+// dumpDescriptor logs the storage schema of the table and the descriptor
+// derived from it when dump_descriptor is enabled.
+func (g *gcpBigQueryOutput) dumpDescriptor(ts *storage.TableSchema, td *tableDescriptor) {
+	if !g.conf.DumpDescriptor {
+		return
+	}
+	opts := prototext.MarshalOptions{Multiline: true}
+	g.log.Infof("storage schema of table %v:\n%s", g.conf.TableID, opts.Format(ts))
+	g.log.Infof("proto descriptor of table %v:\n%s", g.conf.TableID, opts.Format(td.dp))
+}
+
+func (g *gcpBigQueryOutput) schemaCacheKey() string {
+	return fmt.Sprintf("gcp_bigquery_stream/schema/%s/%s/%s", g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID)
+}
+
+// cachedTableSchema reads the table schema through the configured cache
+// resource, so that outputs writing to the same table fetch it only once.
+func (g *gcpBigQueryOutput) cachedTableSchema(ctx context.Context, client *bigquery.Client, mwClient *managedwriter.Client) (*storage.TableSchema, error) {
+	if g.conf.SchemaCache == "" {
+		return g.tableSchema(ctx, client, mwClient)
+	}
+
+	key := g.schemaCacheKey()
+	var ts *storage.TableSchema
+	var cacheErr error
+	if err := g.mgr.AccessCache(ctx, g.conf.SchemaCache, func(c service.Cache) {
+		b, err := c.Get(ctx, key)
+		if err != nil {
+			if !errors.Is(err, service.ErrKeyNotFound) {
+				cacheErr = err
+			}
+			return
+		}
+		ts = &storage.TableSchema{}
+		if cacheErr = proto.Unmarshal(b, ts); cacheErr != nil {
+			ts = nil
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("error accessing schema cache: %w", err)
+	}
+	if cacheErr != nil {
+		g.log.Warnf("ignoring cached schema of table %v: %v", g.conf.TableID, cacheErr)
+	}
+	if ts != nil {
+		return ts, nil
+	}
+
+	ts, err := g.tableSchema(ctx, client, mwClient)
+	if err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(ts)
+	if err != nil {
+		return nil, err
+	}
+	var ttl *time.Duration
+	if g.conf.SchemaCacheTTL > 0 {
+		ttl = &g.conf.SchemaCacheTTL
+	}
+	if err := g.mgr.AccessCache(ctx, g.conf.SchemaCache, func(c service.Cache) {
+		if err := c.Set(ctx, key, b, ttl); err != nil {
+			g.log.Warnf("unable to cache schema of table %v: %v", g.conf.TableID, err)
+		}
+	}); err != nil {
+		g.log.Warnf("unable to cache schema of table %v: %v", g.conf.TableID, err)
+	}
+	return ts, nil
+}
@@ -0,0 +1,383 @@
+package output
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	spillSuffix = ".spill"
+
+	// spillMismatchSuffix is appended to segments set aside because they were
+	// written for a different table schema than the current one.
+	spillMismatchSuffix = ".mismatch"
+)
+
+var errSpillFull = errors.New("spill buffer is full")
+
+// spillQueue is a disk-backed queue of converted rows that could not be
+// appended because BigQuery was unavailable or throttling. Each segment file
+// holds the rows of one append as length-prefixed proto bytes and is named
+// after a sequence number, so segments are replayed in the order they were
+// spilled, including segments left behind by a previous run. The name also
+// carries the fingerprint of the schema the rows were encoded with, as the
+// proto bytes cannot be appended with any other.
+type spillQueue struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	segments []spillSegment
+	size     int64
+	nextSeq  uint64
+
+	ctx      context.Context
+	shutdown context.CancelFunc
+	stopped  chan struct{}
+
+	metrics spillMetrics
+}
+
+type spillSegment struct {
+	seq     uint64
+	schema  string
+	size    int64
+	created time.Time
+}
+
+// spillMetrics report the backlog of a spill queue, labelled by the
+// destination its rows are replayed to, which is empty until it is known.
+type spillMetrics struct {
+	destination func() string
+	depth       *service.MetricGauge
+	segments    *service.MetricGauge
+	age         *service.MetricGauge
+	replayed    *service.MetricCounter
+}
+
+func newSpillQueue(dir string, maxBytes int64) (*spillQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating spill directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spill directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &spillQueue{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ctx:      ctx,
+		shutdown: cancel,
+		stopped:  make(chan struct{}),
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), spillSuffix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		name, schema, _ := strings.Cut(name, "-")
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("error reading spill segment: %w", err)
+		}
+		q.segments = append(q.segments, spillSegment{seq: seq, schema: schema, size: info.Size(), created: info.ModTime()})
+		q.size += info.Size()
+		q.nextSeq = max(q.nextSeq, seq+1)
+	}
+	slices.SortFunc(q.segments, func(a, b spillSegment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return q, nil
+}
+
+// spillable reports whether an append error is caused by BigQuery being
+// unavailable or throttling, rather than by the rows themselves.
+func spillable(err error) bool {
+	if errors.Is(err, service.ErrNotConnected) {
+		return true
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted, codes.Internal:
+			return true
+		}
+	}
+	return false
+}
+
+// path returns the file of a segment. Segments spilled before schema
+// fingerprints were recorded have none in their name.
+func (q *spillQueue) path(seg spillSegment) string {
+	if seg.schema == "" {
+		return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seg.seq, spillSuffix))
+	}
+	return filepath.Join(q.dir, fmt.Sprintf("%020d-%s%s", seg.seq, seg.schema, spillSuffix))
+}
+
+// write persists the rows encoded with the schema fingerprinted by schema as
+// a new segment, failing with errSpillFull when the segment would exceed the
+// size limit of the queue.
+func (q *spillQueue) write(rows [][]byte, schema string) error {
+	var buf []byte
+	for _, row := range rows {
+		buf = binary.AppendUvarint(buf, uint64(len(row)))
+		buf = append(buf, row...)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxBytes > 0 && q.size+int64(len(buf)) > q.maxBytes {
+		return errSpillFull
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// partially written segment behind for replay. The rows are acknowledged
+	// once spilled, so the segment and its name are synced before returning.
+	seg := spillSegment{seq: q.nextSeq, schema: schema, size: int64(len(buf)), created: time.Now()}
+	tmp := q.path(seg) + ".tmp"
+	if err := writeFileSync(tmp, buf); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing spill segment: %w", err)
+	}
+	if err := os.Rename(tmp, q.path(seg)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing spill segment: %w", err)
+	}
+	if err := syncDir(q.dir); err != nil {
+		os.Remove(q.path(seg))
+		return fmt.Errorf("error syncing spill directory: %w", err)
+	}
+	q.nextSeq++
+	q.segments = append(q.segments, seg)
+	q.size += seg.size
+	q.report()
+	return nil
+}
+
+// writeFileSync writes data to a new file at path and flushes it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes the entries of a directory to disk, making renames into it
+// durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// oldest returns the oldest segment, or false when the queue is empty.
+func (q *spillQueue) oldest() (spillSegment, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.segments) == 0 {
+		return spillSegment{}, false
+	}
+	return q.segments[0], true
+}
+
+func (q *spillQueue) read(seg spillSegment) ([][]byte, error) {
+	f, err := os.Open(q.path(seg))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var rows [][]byte
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]byte, n)
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func (q *spillQueue) remove(seg spillSegment) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := os.Remove(q.path(seg)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.forget(seg)
+	return nil
+}
+
+// setAside moves a segment out of the queue, keeping its file on disk under
+// a name that is not replayed.
+func (q *spillQueue) setAside(seg spillSegment) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := os.Rename(q.path(seg), q.path(seg)+spillMismatchSuffix); err != nil {
+		return err
+	}
+	q.forget(seg)
+	return nil
+}
+
+// forget drops a segment from the queue, the caller must hold mu.
+func (q *spillQueue) forget(seg spillSegment) {
+	q.segments = slices.DeleteFunc(q.segments, func(s spillSegment) bool {
+		return s.seq == seg.seq
+	})
+	q.size -= seg.size
+	q.report()
+}
+
+// report updates the backlog metrics of the queue, the caller must hold mu.
+func (q *spillQueue) report() {
+	var age time.Duration
+	if len(q.segments) > 0 {
+		age = time.Since(q.segments[0].created)
+	}
+	destination := q.metrics.destination()
+	if destination == "" {
+		return
+	}
+	q.metrics.depth.Set(q.size, destination)
+	q.metrics.segments.Set(int64(len(q.segments)), destination)
+	q.metrics.age.Set(age.Nanoseconds(), destination)
+}
+
+// replay periodically appends spilled segments in order, stopping at the
+// first failure until the next interval. Segments written for a schema other
+// than the one returned by schema are set aside rather than replayed, and
+// nothing is replayed while schema returns an empty fingerprint.
+func (q *spillQueue) replay(interval time.Duration, schema func() string, appendRows func(context.Context, [][]byte) error, log *service.Logger) {
+	defer close(q.stopped)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-q.ctx.Done():
+			return
+		}
+		q.mu.Lock()
+		q.report()
+		q.mu.Unlock()
+		for {
+			seg, ok := q.oldest()
+			if !ok {
+				break
+			}
+			current := schema()
+			if current == "" {
+				break
+			}
+			if seg.schema != "" && seg.schema != current {
+				// Proto bytes decode against any schema with matching field
+				// numbers, so the rows would silently land in the wrong
+				// columns.
+				log.Errorf("setting aside spill segment %v written for a different table schema", q.path(seg))
+				if err := q.setAside(seg); err != nil {
+					log.Errorf("error setting aside spill segment %v: %v", q.path(seg), err)
+					break
+				}
+				continue
+			}
+			rows, err := q.read(seg)
+			if err != nil {
+				// A segment that cannot be read will never succeed, so it is
+				// dropped rather than blocking the rest of the queue.
+				log.Errorf("dropping unreadable spill segment %v: %v", q.path(seg), err)
+			} else if err = appendRows(q.ctx, rows); err != nil {
+				log.Debugf("replaying spill segment %v failed: %v", q.path(seg), err)
+				break
+			}
+			if err := q.remove(seg); err != nil {
+				log.Errorf("error removing spill segment %v: %v", q.path(seg), err)
+				break
+			}
+			if err == nil {
+				q.metrics.replayed.Incr(int64(len(rows)), q.metrics.destination())
+				log.Debugf("replayed %d spilled rows", len(rows))
+			}
+		}
+	}
+}
+
+func (q *spillQueue) close() {
+	q.shutdown()
+	<-q.stopped
+}
+
+// spillRows writes rows of a failed append to the spill queue, returning the
+// original error when the rows cannot be spilled.
+func (g *gcpBigQueryOutput) spillRows(rows [][]byte, err error) error {
+	if g.spill == nil || !spillable(err) {
+		return err
+	}
+	if serr := g.spill.write(rows, g.schemaFingerprint()); serr != nil {
+		g.log.Warnf("unable to spill %d rows after append failure: %v", len(rows), serr)
+		return err
+	}
+	g.log.Debugf("spilled %d rows to disk after append failure: %v", len(rows), err)
+	return nil
+}
+
+// schemaFingerprint identifies the descriptor rows are currently encoded with,
+// or is empty when the output is not connected.
+func (g *gcpBigQueryOutput) schemaFingerprint() string {
+	g.connMut.RLock()
+	dp := g.descriptorProto
+	g.connMut.RUnlock()
+	if dp == nil {
+		return ""
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(dp)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
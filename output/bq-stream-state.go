@@ -0,0 +1,50 @@
+package output
+
+import (
+	"fmt"
+)
+
+const (
+	streamStateConnected    = "connected"
+	streamStateReconnecting = "reconnecting"
+	streamStateDraining     = "draining"
+	streamStateFailed       = "failed"
+)
+
+var streamStates = []string{streamStateConnected, streamStateReconnecting, streamStateDraining, streamStateFailed}
+
+// destination names the table the output writes to as project.dataset.table.
+func (g *gcpBigQueryOutput) destination() string {
+	if d := g.dest.Load(); d != nil {
+		return *d
+	}
+	return ""
+}
+
+// resolveDestination records the destination label once the project of the
+// table is known, which is only after connecting when it is detected from the
+// credentials.
+func (g *gcpBigQueryOutput) resolveDestination() {
+	d := fmt.Sprintf("%s.%s.%s", g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID)
+	g.dest.Store(&d)
+}
+
+// setState reports the state of the stream through the bigquery_stream_state
+// gauge, which is 1 for the current state of the destination and 0 for the
+// others. An empty state clears every state, once the output is closed.
+func (g *gcpBigQueryOutput) setState(state string) {
+	g.stateMut.Lock()
+	defer g.stateMut.Unlock()
+	destination := g.destination()
+	if destination == "" {
+		// The project is not known until the output has connected.
+		return
+	}
+	for _, s := range streamStates {
+		var v int64
+		if s == state {
+			v = 1
+		}
+		g.mState.Set(v, destination, s)
+	}
+}
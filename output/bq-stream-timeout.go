@@ -0,0 +1,66 @@
+package output
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// appendRows sends rows on the stream, bounded by append_timeout. The
+// deadline also covers retries of the append made by the client, and is
+// released once its result is ready. The append is traced until its result
+// is ready.
+func (g *gcpBigQueryOutput) appendRows(ctx context.Context, ms *managedwriter.ManagedStream, rows [][]byte) (*managedwriter.AppendResult, error) {
+	g.lastAppend.Store(time.Now().UnixNano())
+	ctx, span := g.startAppendSpan(ctx, ms, rows)
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if g.conf.AppendTimeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, g.conf.AppendTimeout)
+	}
+	result, err := ms.AppendRows(callCtx, rows)
+	if err != nil {
+		cancel()
+		err = timeoutError(ctx, callCtx, err, "AppendRows", g.conf.AppendTimeout)
+		endSpan(span, err)
+		return nil, err
+	}
+	go func() {
+		<-result.Ready()
+		cancel()
+		_, err := result.GetResult(context.Background())
+		endSpan(span, err)
+	}()
+	return result, nil
+}
+
+// getResult waits for the result of an append, bounded by result_timeout.
+// Appends whose response reports an updated table schema are counted.
+func (g *gcpBigQueryOutput) getResult(ctx context.Context, result *managedwriter.AppendResult) (int64, error) {
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if g.conf.ResultTimeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, g.conf.ResultTimeout)
+	}
+	defer cancel()
+	o, err := result.GetResult(callCtx)
+	if err != nil {
+		return o, timeoutError(ctx, callCtx, err, "GetResult", g.conf.ResultTimeout)
+	}
+	if schema, _ := result.UpdatedSchema(callCtx); schema != nil {
+		g.mSchemaUpdates.Incr(1)
+		g.log.Infof("BigQuery reported an updated table schema in an append response")
+	}
+	return o, nil
+}
+
+// timeoutError converts the expiry of a per-call deadline into a
+// DEADLINE_EXCEEDED status, so that it is retried like a server side timeout.
+// Cancellation of the parent context is returned as is.
+func timeoutError(ctx, callCtx context.Context, err error, call string, timeout time.Duration) error {
+	if ctx.Err() != nil || callCtx.Err() == nil {
+		return err
+	}
+	return status.Errorf(codes.DeadlineExceeded, "%s timed out after %v: %v", call, timeout, err)
+}
@@ -0,0 +1,122 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenSourceConfig describes where access tokens come from in the token auth
+// mode, for environments where a central broker mints short-lived tokens.
+type tokenSourceConfig struct {
+	value           string
+	command         []string
+	url             string
+	refreshInterval time.Duration
+}
+
+func (c tokenSourceConfig) enabled() bool {
+	return c.value != "" || len(c.command) > 0 || c.url != ""
+}
+
+// tokenSource returns a token source for the configured token, which is
+// refreshed by running the command or fetching the URL again shortly before
+// it expires.
+func (c tokenSourceConfig) tokenSource() oauth2.TokenSource {
+	if c.value != "" {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.value, TokenType: "Bearer"})
+	}
+	return oauth2.ReuseTokenSource(nil, c)
+}
+
+// Token obtains a new access token from the command or URL.
+func (c tokenSourceConfig) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var tok *oauth2.Token
+	var err error
+	if len(c.command) > 0 {
+		tok, err = c.commandToken(ctx)
+	} else {
+		tok, err = c.urlToken(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tok.Expiry.IsZero() {
+		tok.Expiry = time.Now().Add(c.refreshInterval)
+	}
+	return tok, nil
+}
+
+func (c tokenSourceConfig) commandToken(ctx context.Context) (*oauth2.Token, error) {
+	out, err := exec.CommandContext(ctx, c.command[0], c.command[1:]...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("token command failed: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("token command failed: %w", err)
+	}
+	return parseToken(out)
+}
+
+// urlToken fetches a token from a URL such as a sidecar emulating the GCE
+// metadata server, which requires the Metadata-Flavor header.
+func (c tokenSourceConfig) urlToken(ctx context.Context) (*oauth2.Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching token: %v", resp.Status)
+	}
+	return parseToken(body)
+}
+
+// parseToken reads a token either as JSON in the format of the GCE metadata
+// server, with access_token and expires_in, or as the plain token.
+func parseToken(b []byte) (*oauth2.Token, error) {
+	b = []byte(strings.TrimSpace(string(b)))
+	if len(b) > 0 && b[0] == '{' {
+		var resp struct {
+			AccessToken string `json:"access_token"`
+			TokenType   string `json:"token_type"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return nil, fmt.Errorf("invalid token response: %w", err)
+		}
+		if resp.AccessToken == "" {
+			return nil, errors.New("token response has no access_token")
+		}
+		tok := &oauth2.Token{AccessToken: resp.AccessToken, TokenType: resp.TokenType}
+		if resp.ExpiresIn > 0 {
+			tok.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+		}
+		return tok, nil
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty access token")
+	}
+	return &oauth2.Token{AccessToken: string(b), TokenType: "Bearer"}, nil
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"context"
+	"strings"
+
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type appendAttemptKey struct{}
+
+// withAppendAttempt records the retry attempt of an append on its context so
+// that it can be set on the append span.
+func withAppendAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, appendAttemptKey{}, attempt)
+}
+
+// startBatchSpan starts the span of a batch write, linked to the spans of the
+// messages of the batch.
+func (g *gcpBigQueryOutput) startBatchSpan(ctx context.Context, batch service.MessageBatch) (context.Context, trace.Span) {
+	var links []trace.Link
+	for _, msg := range batch {
+		if sc := trace.SpanContextFromContext(msg.Context()); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	return g.tracer.Start(ctx, "gcp_bigquery_stream.write_batch",
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("bigquery.table", managedwriter.TableParentFromParts(g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID)),
+			attribute.Int("messages", len(batch)),
+		),
+	)
+}
+
+// startAppendSpan starts the span of a single append on a stream.
+func (g *gcpBigQueryOutput) startAppendSpan(ctx context.Context, ms *managedwriter.ManagedStream, rows [][]byte) (context.Context, trace.Span) {
+	var size int
+	for _, row := range rows {
+		size += len(row)
+	}
+	attempt, _ := ctx.Value(appendAttemptKey{}).(int)
+	stream := ms.StreamName()
+	table, _, _ := strings.Cut(stream, "/streams/")
+	return g.tracer.Start(ctx, "gcp_bigquery_stream.append_rows",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("bigquery.table", table),
+			attribute.String("bigquery.stream", stream),
+			attribute.Int("rows", len(rows)),
+			attribute.Int("bytes", size),
+			attribute.Int("retry_attempt", attempt),
+		),
+	)
+}
+
+// streamTraceID derives the trace ID that BigQuery records for the
+// connections of a stream from the output label and the trace active when
+// the stream is opened, which is the connect or reconnect span. GCP side logs
+// of the stream can then be correlated with that trace.
+func (g *gcpBigQueryOutput) streamTraceID(ctx context.Context) string {
+	id := "rp-connect-bq-stream"
+	if label := g.mgr.Label(); label != "" {
+		id += ":" + label
+	}
+	span := trace.SpanFromContext(ctx)
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		id += ":" + sc.TraceID().String()
+		span.SetAttributes(attribute.String("bigquery.trace_id", id))
+	}
+	return id
+}
+
+// endSpan ends a span with the outcome of the operation it covers.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+		span.SetAttributes(attribute.String("outcome", "failure"))
+	} else {
+		span.SetAttributes(attribute.String("outcome", "success"))
+	}
+	span.End()
+}
@@ -0,0 +1,252 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
+)
+
+// schemaField is a node of the destination table schema keyed by the JSON
+// key that maps onto it, used to rewrite decoded rows before conversion.
+type schemaField struct {
+	schema   *storage.TableFieldSchema
+	children map[string]*schemaField
+}
+
+func newSchemaFields(fields []*storage.TableFieldSchema) map[string]*schemaField {
+	m := make(map[string]*schemaField, len(fields))
+	for _, f := range fields {
+		sf := &schemaField{schema: f}
+		if f.GetType() == storage.TableFieldSchema_STRUCT {
+			sf.children = newSchemaFields(f.GetFields())
+		}
+		m[f.GetName()] = sf
+	}
+	return m
+}
+
+func (s *schemaField) repeated() bool {
+	return s.schema.GetMode() == storage.TableFieldSchema_REPEATED
+}
+
+// rowTransformer applies the configured JSON rewrites to a message before it
+// is unmarshalled into the table descriptor.
+type rowTransformer struct {
+	promoteToRepeated bool
+	dropNullFields    bool
+	timestampLocation *time.Location
+	decimalComma      bool
+}
+
+func newRowTransformer(conf gcpBigQueryOutputConfig) *rowTransformer {
+	return &rowTransformer{
+		promoteToRepeated: conf.PromoteToRepeated,
+		dropNullFields:    conf.DropNullFields,
+		timestampLocation: conf.DefaultTimezone,
+		decimalComma:      conf.DecimalComma,
+	}
+}
+
+// enabled returns true when at least one rewrite is configured, allowing the
+// decode/encode round trip to be skipped entirely otherwise.
+func (t *rowTransformer) enabled() bool {
+	return t.promoteToRepeated || t.dropNullFields || t.timestampLocation != nil || t.decimalComma
+}
+
+func (t *rowTransformer) transform(msgBytes []byte, fields map[string]*schemaField) ([]byte, error) {
+	if !t.enabled() {
+		return msgBytes, nil
+	}
+
+	row, err := decodeJSONRow(msgBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.transformObject(row, fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(row)
+}
+
+// decodeJSONRow decodes a message into a generic JSON object, keeping numbers
+// as json.Number so that no precision is lost before encoding. Like protojson,
+// it rejects duplicate keys and data following the object.
+func decodeJSONRow(msgBytes []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(msgBytes))
+	dec.UseNumber()
+
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message as a JSON object: %w", err)
+	}
+	row, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("failed to decode message as a JSON object: got %v", jsonTypeName(v))
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("failed to decode message as a JSON object: unexpected data after the object")
+	}
+	return row, nil
+}
+
+// decodeJSONValue decodes the next value from dec, failing on objects with
+// duplicate keys.
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := map[string]any{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			if _, exists := obj[key]; exists {
+				return nil, fmt.Errorf("duplicate field %q", key)
+			}
+			if obj[key], err = decodeJSONValue(dec); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	}
+	return tok, nil
+}
+
+// jsonTypeName names the JSON type of a decoded value for error messages.
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
+
+func (t *rowTransformer) transformObject(obj map[string]any, fields map[string]*schemaField) error {
+	for k, v := range obj {
+		if v == nil {
+			if t.dropNullFields {
+				delete(obj, k)
+			}
+			continue
+		}
+		f, ok := fields[k]
+		if !ok {
+			continue
+		}
+		if !f.repeated() {
+			nv, err := t.transformValue(f, v)
+			if err != nil {
+				return fmt.Errorf("field %v: %w", k, err)
+			}
+			obj[k] = nv
+			continue
+		}
+		arr, isArr := v.([]any)
+		if !isArr {
+			if !t.promoteToRepeated {
+				continue
+			}
+			arr = []any{v}
+		}
+		for i, e := range arr {
+			nv, err := t.transformValue(f, e)
+			if err != nil {
+				return fmt.Errorf("field %v[%d]: %w", k, i, err)
+			}
+			arr[i] = nv
+		}
+		obj[k] = arr
+	}
+	return nil
+}
+
+func (t *rowTransformer) transformValue(f *schemaField, v any) (any, error) {
+	switch x := v.(type) {
+	case map[string]any:
+		if f.children != nil {
+			return x, t.transformObject(x, f.children)
+		}
+	case string:
+		switch f.schema.GetType() {
+		case storage.TableFieldSchema_TIMESTAMP:
+			if t.timestampLocation != nil {
+				return parseTimestampString(x, t.timestampLocation)
+			}
+		case storage.TableFieldSchema_NUMERIC,
+			storage.TableFieldSchema_BIGNUMERIC,
+			storage.TableFieldSchema_DOUBLE,
+			storage.TableFieldSchema_INT64:
+			if t.decimalComma {
+				return parseCommaDecimal(x, f.schema.GetType())
+			}
+		}
+	}
+	return v, nil
+}
+
+var naiveTimestampLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimestampString converts a TIMESTAMP column string into epoch
+// microseconds. Strings carrying zone information are used as is, naive ones
+// are interpreted within loc. Integer strings are passed through untouched as
+// they are already accepted as epoch microseconds.
+func parseTimestampString(s string, loc *time.Location) (any, error) {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return s, nil
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return json.Number(strconv.FormatInt(ts.UnixMicro(), 10)), nil
+	}
+	if ts, err := time.Parse("2006-01-02 15:04:05.999999999Z07:00", s); err == nil {
+		return json.Number(strconv.FormatInt(ts.UnixMicro(), 10)), nil
+	}
+	for _, layout := range naiveTimestampLayouts {
+		if ts, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return json.Number(strconv.FormatInt(ts.UnixMicro(), 10)), nil
+		}
+	}
+	return nil, fmt.Errorf("unable to parse %q as a TIMESTAMP", s)
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeJSONRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:  "object",
+			input: `{"a":1.50,"b":["x",null],"c":{"d":true}}`,
+			want: map[string]any{
+				"a": json.Number("1.50"),
+				"b": []any{"x", nil},
+				"c": map[string]any{"d": true},
+			},
+		},
+		{
+			name:  "surrounding whitespace",
+			input: " {\"a\":\"b\"}\n",
+			want:  map[string]any{"a": "b"},
+		},
+		{name: "trailing object", input: `{"a":1}{"a":2}`, wantErr: true},
+		{name: "trailing garbage", input: `{"a":1} x`, wantErr: true},
+		{name: "duplicate key", input: `{"a":1,"a":2}`, wantErr: true},
+		{name: "nested duplicate key", input: `{"a":[{"b":1,"b":1}]}`, wantErr: true},
+		{name: "null", input: `null`, wantErr: true},
+		{name: "array", input: `[{"a":1}]`, wantErr: true},
+		{name: "truncated", input: `{"a":`, wantErr: true},
+		{name: "empty", input: ``, wantErr: true},
+		{name: "malformed", input: `{"a" 1}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeJSONRow([]byte(test.input))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// customTransport reports whether the clients need a transport other than
+// the default one of the client libraries.
+func (conf gcpBigQueryOutputConfig) customTransport() bool {
+	return conf.TLS != nil || conf.Proxy != nil
+}
+
+// httpClientOptions returns the options of the BigQuery client. With a custom
+// transport the client is given an HTTP client authenticated with the
+// credential options, as the client libraries ignore credentials once an HTTP
+// client is given.
+func httpClientOptions(ctx context.Context, conf gcpBigQueryOutputConfig, opt []option.ClientOption) ([]option.ClientOption, error) {
+	if !conf.customTransport() {
+		return opt, nil
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.TLS != nil {
+		base.TLSClientConfig = conf.TLS
+	}
+	if conf.Proxy != nil {
+		// Credentials in the proxy URL are sent as Proxy-Authorization.
+		base.Proxy = http.ProxyURL(conf.Proxy)
+	}
+	authOpt := opt
+	if len(conf.Scopes) == 0 {
+		authOpt = append(authOpt[:len(authOpt):len(authOpt)], option.WithScopes(bigquery.Scope))
+	}
+	rt, err := htransport.NewTransport(ctx, base, authOpt...)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: rt})}, nil
+}
+
+// grpcClientOptions adds the options of a custom transport to the options of
+// the managed writer client.
+func grpcClientOptions(conf gcpBigQueryOutputConfig, opt []option.ClientOption) []option.ClientOption {
+	if conf.TLS != nil {
+		opt = append(opt, option.WithGRPCDialOption(grpc.WithTransportCredentials(credentials.NewTLS(conf.TLS))))
+	}
+	if conf.Proxy != nil {
+		opt = append(opt, option.WithGRPCDialOption(grpc.WithContextDialer(proxyDialer(conf.Proxy))))
+	}
+	return opt
+}
+
+// parseProxyURL parses the proxy URL, adding the username and password when
+// they are configured separately.
+func parseProxyURL(rawURL, username, password string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if u.Scheme != "http" || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q, expected http://host:port", u.Redacted())
+	}
+	if username != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	return u, nil
+}
+
+// proxyDialer returns a gRPC dialer that tunnels connections through an HTTP
+// proxy with CONNECT. gRPC only picks up proxies from the environment, and
+// not at all once a dialer is given.
+func proxyDialer(proxy *url.URL) func(context.Context, string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", proxy.Host)
+		if err != nil {
+			return nil, fmt.Errorf("error dialing proxy: %w", err)
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+			defer conn.SetDeadline(time.Time{})
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: http.Header{},
+		}
+		if u := proxy.User; u != nil {
+			password, _ := u.Password()
+			req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+		}
+		if err := req.Write(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error sending proxy CONNECT: %w", err)
+		}
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error reading proxy CONNECT response: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("proxy CONNECT to %v failed: %v", addr, resp.Status)
+		}
+		if br.Buffered() > 0 {
+			return &bufferedConn{Conn: conn, r: br}, nil
+		}
+		return conn, nil
+	}
+}
+
+// bufferedConn is a connection whose first bytes were already read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
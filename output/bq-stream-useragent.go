@@ -0,0 +1,22 @@
+package output
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+const userAgentProduct = "rp-connect-bq-stream"
+
+// userAgent returns the user agent of the clients, identifying the plugin and
+// the version of the binary it was built into, followed by the configured
+// suffix.
+func userAgent(suffix string) string {
+	ua := userAgentProduct
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		ua += "/" + info.Main.Version
+	}
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	eventReconnect     = "reconnect"
+	eventBreakerOpen   = "breaker_open"
+	eventBreakerClose  = "breaker_close"
+	eventSchemaRefresh = "schema_refresh"
+	eventBatchFailed   = "batch_failed"
+
+	// webhookQueueSize bounds the events waiting to be posted, events
+	// beyond it are dropped rather than blocking writes.
+	webhookQueueSize = 64
+)
+
+var lifecycleEvents = []string{eventReconnect, eventBreakerOpen, eventBreakerClose, eventSchemaRefresh, eventBatchFailed}
+
+// lifecycleEvent is the JSON body posted to the webhook.
+type lifecycleEvent struct {
+	Event       string    `json:"event"`
+	Destination string    `json:"destination"`
+	Label       string    `json:"label,omitempty"`
+	Time        time.Time `json:"time"`
+	Error       string    `json:"error,omitempty"`
+	Messages    int       `json:"messages,omitempty"`
+}
+
+// webhook posts lifecycle events to an HTTP endpoint in the background, so
+// that a slow or unavailable endpoint never stalls the output.
+type webhook struct {
+	url     string
+	events  map[string]bool
+	timeout time.Duration
+	client  *http.Client
+	queue   chan lifecycleEvent
+}
+
+func newWebhook(url string, events []string, timeout time.Duration) *webhook {
+	w := &webhook{
+		url:     url,
+		events:  make(map[string]bool, len(events)),
+		timeout: timeout,
+		client:  &http.Client{},
+		queue:   make(chan lifecycleEvent, webhookQueueSize),
+	}
+	for _, e := range events {
+		w.events[e] = true
+	}
+	return w
+}
+
+func (w *webhook) run(ctx context.Context, log *service.Logger) {
+	for {
+		select {
+		case e := <-w.queue:
+			if err := w.post(ctx, e); err != nil {
+				log.Warnf("unable to post %v event to lifecycle webhook: %v", e.Event, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *webhook) post(ctx context.Context, e lifecycleEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
+
+// emit queues a lifecycle event for the webhook, if one is configured and
+// subscribed to the event. messages is the number of messages the event
+// concerns, if any.
+func (g *gcpBigQueryOutput) emit(event string, err error, messages int) {
+	if g.webhook == nil || !g.webhook.events[event] {
+		return
+	}
+	e := lifecycleEvent{
+		Event:       event,
+		Destination: g.destination(),
+		Label:       g.mgr.Label(),
+		Time:        time.Now(),
+		Messages:    messages,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	select {
+	case g.webhook.queue <- e:
+	default:
+		g.log.Warnf("lifecycle webhook queue is full, dropping %v event", event)
+	}
+}
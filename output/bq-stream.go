@@ -2,12 +2,18 @@ package output
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/googleapis/gax-go/v2"
 	"github.com/googleapis/gax-go/v2/apierror"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -16,24 +22,118 @@ import (
 	"cloud.google.com/go/bigquery/storage/managedwriter"
 	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
 	"github.com/redpanda-data/benthos/v4/public/service"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/googleapi"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
 	"google.golang.org/genproto/googleapis/cloud/bigquery/storage/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
-	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 type gcpBigQueryOutputConfig struct {
 	ProjectID       string
+	BillingProject  string
 	DatasetID       string
 	TableID         string
 	AllowPartial    bool
 	DiscardUnknown  bool
-	CredentialsJSON string
+	CredentialsJSON *service.InterpolatedString
+	CredentialsFile string
+	Token           tokenSourceConfig
+
+	ImpersonateServiceAccount string
+	ImpersonateDelegates      []string
+	Scopes                    []string
+	QuotaProject              string
+	EndpointRegion            string
+	HTTPEndpoint              string
+	GRPCEndpoint              string
+	InsecureEndpoints         bool
+	TLS                       *tls.Config
+	Proxy                     *url.URL
+	UserAgent                 string
+
+	// clientOptions are appended to the options of both clients, which lets
+	// tests and embedding code inject options that have no config field.
+	clientOptions []option.ClientOption
+
+	PromoteToRepeated          bool
+	DropNullFields             bool
+	MissingValueInterpretation storage.AppendRowsRequest_MissingValueInterpretation
+	MaxRowBytes                int
+	RowSizeAction              string
+	TruncateColumns            []string
+	MaxMessageBytes            int
+	MaxJSONDepth               int
+	DefaultTimezone            *time.Location
+	DecimalComma               bool
+	RawJSONColumn              string
+	RawJSONMetadataColumns     map[string]string
+	FastEncoding               bool
+	MaxRowsPerAppend           int
+	MaxBytesPerAppend          int
+	MaxInflightRequests        int
+	MaxInflightBytes           int
+	AdaptiveAppend             bool
+	AdaptiveMinRows            int
+	AdaptiveMaxRows            int
+	AdaptiveTargetLatency      time.Duration
+	KeepaliveTime              time.Duration
+	KeepaliveTimeout           time.Duration
+	KeepalivePermitWithout     bool
+	ConnectionPoolSize         int
+	ResultQueueSize            int
+	AppendTimeout              time.Duration
+	ResultTimeout              time.Duration
+	BatchDeadline              time.Duration
+	SpillPath                  string
+	SpillMaxBytes              int64
+	SpillReplayInterval        time.Duration
+	SkipExistenceCheck         bool
+	PermissionCheck            bool
+	LivenessInterval           time.Duration
+	HeartbeatInterval          time.Duration
+	WebhookURL                 string
+	WebhookEvents              []string
+	WebhookTimeout             time.Duration
+	SchemaCache                string
+	SchemaCacheTTL             time.Duration
+	Retry                      retryPolicy
+	QuotaBackoff               retryPolicy
+	ReconnectBackoff           retryPolicy
+	ReconnectResetAfter        time.Duration
+	FailoverProjectID          string
+	FailoverDatasetID          string
+	FailoverTableID            string
+	FailoverAfter              int
+	FailoverProbeInterval      time.Duration
+	ErrorsTable                string
+	ErrorClassification        map[string]errorClass
+	IsolatePoisonRows          bool
+	AckGranularity             string
+	RetryBudgetName            string
+	RetryBudgetRate            float64
+	RetryBudgetBurst           int
+	Strict                     bool
+	BreakerThreshold           int
+	MaxConsecutiveFailures     int
+	BreakerCooldown            time.Duration
+	WriteRetries               bool
+	ConnectBackoff             gax.Backoff
+	SlowConversionThreshold    time.Duration
+	ErrorLogFirst              int
+	ErrorLogThereafter         int
+	ErrorLogInterval           time.Duration
+	DumpDescriptor             bool
+	CostPerGiB                 float64
+	TrustedSource              bool
+	Dedupe                     bool
+	DedupeKeyColumns           []string
 }
 
 func gcpBigQueryOutputConfigFromParsed(conf *service.ParsedConfig) (gconf gcpBigQueryOutputConfig, err error) {
@@ -43,6 +143,13 @@ func gcpBigQueryOutputConfigFromParsed(conf *service.ParsedConfig) (gconf gcpBig
 	if gconf.ProjectID == "" {
 		gconf.ProjectID = bigquery.DetectProjectID
 	}
+	if gconf.BillingProject, err = conf.FieldString("billing_project"); err != nil {
+		return
+	}
+	if gconf.BillingProject != "" && gconf.ProjectID == bigquery.DetectProjectID {
+		err = errors.New("billing_project requires project to be set")
+		return
+	}
 	if gconf.DatasetID, err = conf.FieldString("dataset"); err != nil {
 		return
 	}
@@ -55,50 +162,532 @@ func gcpBigQueryOutputConfigFromParsed(conf *service.ParsedConfig) (gconf gcpBig
 	if gconf.DiscardUnknown, err = conf.FieldBool("discard_unknown"); err != nil {
 		return
 	}
-	if gconf.CredentialsJSON, err = conf.FieldString("credentials_json"); err != nil {
+	if gconf.CredentialsJSON, err = conf.FieldInterpolatedString("credentials_json"); err != nil {
+		return
+	}
+	if gconf.CredentialsFile, err = conf.FieldString("credentials_file"); err != nil {
+		return
+	}
+	if v, static := gconf.CredentialsJSON.Static(); (v != "" || !static) && gconf.CredentialsFile != "" {
+		err = errors.New("only one of credentials_json and credentials_file may be set")
+		return
+	}
+	if gconf.Token.value, err = conf.FieldString("token", "value"); err != nil {
+		return
+	}
+	if gconf.Token.command, err = conf.FieldStringList("token", "command"); err != nil {
+		return
+	}
+	if gconf.Token.url, err = conf.FieldString("token", "url"); err != nil {
+		return
+	}
+	if gconf.Token.refreshInterval, err = conf.FieldDuration("token", "refresh_interval"); err != nil {
+		return
+	}
+	if gconf.Token.enabled() {
+		sources := 0
+		for _, set := range []bool{gconf.Token.value != "", len(gconf.Token.command) > 0, gconf.Token.url != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources > 1 {
+			err = errors.New("only one of token value, command and url may be set")
+			return
+		}
+		if v, static := gconf.CredentialsJSON.Static(); v != "" || !static || gconf.CredentialsFile != "" {
+			err = errors.New("token cannot be combined with credentials_json or credentials_file")
+			return
+		}
+	}
+	if gconf.ImpersonateServiceAccount, err = conf.FieldString("impersonate_service_account", "target"); err != nil {
+		return
+	}
+	if gconf.ImpersonateDelegates, err = conf.FieldStringList("impersonate_service_account", "delegates"); err != nil {
+		return
+	}
+	if gconf.Scopes, err = conf.FieldStringList("scopes"); err != nil {
+		return
+	}
+	if gconf.QuotaProject, err = conf.FieldString("quota_project"); err != nil {
+		return
+	}
+	if gconf.EndpointRegion, err = conf.FieldString("endpoint_region"); err != nil {
+		return
+	}
+	if gconf.HTTPEndpoint, err = conf.FieldString("http_endpoint"); err != nil {
+		return
+	}
+	if gconf.GRPCEndpoint, err = conf.FieldString("grpc_endpoint"); err != nil {
+		return
+	}
+	if gconf.InsecureEndpoints, err = conf.FieldBool("insecure"); err != nil {
+		return
+	}
+	var tlsEnabled bool
+	if gconf.TLS, tlsEnabled, err = conf.FieldTLSToggled("tls"); err != nil {
+		return
+	}
+	if !tlsEnabled {
+		gconf.TLS = nil
+	}
+	if gconf.UserAgent, err = conf.FieldString("user_agent"); err != nil {
+		return
+	}
+	var proxyURL, proxyUser, proxyPassword string
+	if proxyURL, err = conf.FieldString("proxy", "url"); err != nil {
+		return
+	}
+	if proxyUser, err = conf.FieldString("proxy", "username"); err != nil {
+		return
+	}
+	if proxyPassword, err = conf.FieldString("proxy", "password"); err != nil {
+		return
+	}
+	if proxyURL != "" {
+		if gconf.Proxy, err = parseProxyURL(proxyURL, proxyUser, proxyPassword); err != nil {
+			return
+		}
+	}
+	if gconf.PromoteToRepeated, err = conf.FieldBool("promote_to_repeated"); err != nil {
+		return
+	}
+	if gconf.DropNullFields, err = conf.FieldBool("drop_null_fields"); err != nil {
+		return
+	}
+	var mvi string
+	if mvi, err = conf.FieldString("missing_value_interpretation"); err != nil {
+		return
+	}
+	gconf.MissingValueInterpretation = storage.AppendRowsRequest_MissingValueInterpretation(storage.AppendRowsRequest_MissingValueInterpretation_value[mvi])
+	if gconf.MaxRowBytes, err = conf.FieldInt("max_row_bytes"); err != nil {
+		return
+	}
+	if gconf.MaxRowBytes <= 0 {
+		err = errors.New("max_row_bytes must be greater than 0")
+		return
+	}
+	if gconf.RowSizeAction, err = conf.FieldString("row_size_action"); err != nil {
+		return
+	}
+	if gconf.TruncateColumns, err = conf.FieldStringList("truncate_columns"); err != nil {
+		return
+	}
+	if gconf.RowSizeAction == rowSizeActionTruncate && len(gconf.TruncateColumns) == 0 {
+		err = errors.New("row_size_action truncate requires at least one column in truncate_columns")
+		return
+	}
+	if gconf.MaxMessageBytes, err = conf.FieldInt("max_message_bytes"); err != nil {
+		return
+	}
+	if gconf.MaxJSONDepth, err = conf.FieldInt("max_json_depth"); err != nil {
+		return
+	}
+	var tz string
+	if tz, err = conf.FieldString("default_timezone"); err != nil {
+		return
+	}
+	if tz != "" {
+		if gconf.DefaultTimezone, err = time.LoadLocation(tz); err != nil {
+			err = fmt.Errorf("invalid default_timezone: %w", err)
+			return
+		}
+	}
+	if gconf.DecimalComma, err = conf.FieldBool("decimal_comma"); err != nil {
+		return
+	}
+	if gconf.RawJSONColumn, err = conf.FieldString("raw_json_column"); err != nil {
+		return
+	}
+	if gconf.RawJSONMetadataColumns, err = conf.FieldStringMap("raw_json_metadata_columns"); err != nil {
+		return
+	}
+	if len(gconf.RawJSONMetadataColumns) > 0 && gconf.RawJSONColumn == "" {
+		err = errors.New("raw_json_metadata_columns requires raw_json_column to be set")
+		return
+	}
+	if gconf.FastEncoding, err = conf.FieldBool("fast_encoding"); err != nil {
+		return
+	}
+	if gconf.MaxRowsPerAppend, err = conf.FieldInt("max_rows_per_append"); err != nil {
+		return
+	}
+	if gconf.MaxBytesPerAppend, err = conf.FieldInt("max_bytes_per_append"); err != nil {
+		return
+	}
+	if gconf.MaxBytesPerAppend <= 0 || gconf.MaxBytesPerAppend > maxAppendBytes {
+		err = fmt.Errorf("max_bytes_per_append must be between 1 and %d", maxAppendBytes)
+		return
+	}
+	if gconf.MaxInflightRequests, err = conf.FieldInt("max_inflight_requests"); err != nil {
+		return
+	}
+	if gconf.MaxInflightBytes, err = conf.FieldInt("max_inflight_bytes"); err != nil {
+		return
+	}
+	if gconf.AdaptiveAppend, err = conf.FieldBool("adaptive_append", "enabled"); err != nil {
+		return
+	}
+	if gconf.AdaptiveMinRows, err = conf.FieldInt("adaptive_append", "min_rows"); err != nil {
+		return
+	}
+	if gconf.AdaptiveMaxRows, err = conf.FieldInt("adaptive_append", "max_rows"); err != nil {
+		return
+	}
+	if gconf.AdaptiveTargetLatency, err = conf.FieldDuration("adaptive_append", "target_latency"); err != nil {
+		return
+	}
+	if gconf.AdaptiveAppend && (gconf.AdaptiveMinRows <= 0 || gconf.AdaptiveMaxRows < gconf.AdaptiveMinRows) {
+		err = errors.New("adaptive_append requires 0 < min_rows <= max_rows")
+		return
+	}
+	if gconf.KeepaliveTime, err = conf.FieldDuration("keepalive", "time"); err != nil {
+		return
+	}
+	if gconf.KeepaliveTimeout, err = conf.FieldDuration("keepalive", "timeout"); err != nil {
+		return
+	}
+	if gconf.KeepalivePermitWithout, err = conf.FieldBool("keepalive", "permit_without_stream"); err != nil {
+		return
+	}
+	if gconf.ConnectionPoolSize, err = conf.FieldInt("connection_pool_size"); err != nil {
+		return
+	}
+	if gconf.ResultQueueSize, err = conf.FieldInt("result_queue_size"); err != nil {
+		return
+	}
+	if gconf.AppendTimeout, err = conf.FieldDuration("append_timeout"); err != nil {
+		return
+	}
+	if gconf.ResultTimeout, err = conf.FieldDuration("result_timeout"); err != nil {
+		return
+	}
+	if gconf.BatchDeadline, err = conf.FieldDuration("batch_deadline"); err != nil {
+		return
+	}
+	if gconf.SpillPath, err = conf.FieldString("spill", "path"); err != nil {
+		return
+	}
+	var spillMaxBytes int
+	if spillMaxBytes, err = conf.FieldInt("spill", "max_bytes"); err != nil {
+		return
+	}
+	gconf.SpillMaxBytes = int64(spillMaxBytes)
+	if gconf.SpillReplayInterval, err = conf.FieldDuration("spill", "replay_interval"); err != nil {
+		return
+	}
+	if gconf.SkipExistenceCheck, err = conf.FieldBool("skip_existence_check"); err != nil {
+		return
+	}
+	if gconf.PermissionCheck, err = conf.FieldBool("permission_check"); err != nil {
+		return
+	}
+	if gconf.LivenessInterval, err = conf.FieldDuration("liveness_interval"); err != nil {
+		return
+	}
+	if gconf.HeartbeatInterval, err = conf.FieldDuration("heartbeat_interval"); err != nil {
+		return
+	}
+	if gconf.WebhookURL, err = conf.FieldString("lifecycle_webhook", "url"); err != nil {
+		return
+	}
+	if gconf.WebhookEvents, err = conf.FieldStringList("lifecycle_webhook", "events"); err != nil {
+		return
+	}
+	for _, e := range gconf.WebhookEvents {
+		if !slices.Contains(lifecycleEvents, e) {
+			err = fmt.Errorf("lifecycle_webhook event %q is not one of %v", e, strings.Join(lifecycleEvents, ", "))
+			return
+		}
+	}
+	if gconf.WebhookTimeout, err = conf.FieldDuration("lifecycle_webhook", "timeout"); err != nil {
+		return
+	}
+	if gconf.SchemaCache, err = conf.FieldString("schema_cache"); err != nil {
+		return
+	}
+	if gconf.SchemaCacheTTL, err = conf.FieldDuration("schema_cache_ttl"); err != nil {
+		return
+	}
+	if gconf.Retry.maxAttempts, err = conf.FieldInt("retry", "max_attempts"); err != nil {
+		return
+	}
+	if gconf.Retry.initialBackoff, err = conf.FieldDuration("retry", "initial_backoff"); err != nil {
+		return
+	}
+	if gconf.Retry.maxBackoff, err = conf.FieldDuration("retry", "max_backoff"); err != nil {
+		return
+	}
+	if gconf.Retry.jitter, err = conf.FieldFloat("retry", "jitter"); err != nil {
+		return
+	}
+	if gconf.Retry.maxElapsed, err = conf.FieldDuration("retry", "max_elapsed"); err != nil {
+		return
+	}
+	if gconf.QuotaBackoff.initialBackoff, err = conf.FieldDuration("retry", "quota_initial_backoff"); err != nil {
+		return
+	}
+	if gconf.QuotaBackoff.maxBackoff, err = conf.FieldDuration("retry", "quota_max_backoff"); err != nil {
+		return
+	}
+	gconf.QuotaBackoff.jitter = gconf.Retry.jitter
+	if gconf.RetryBudgetName, err = conf.FieldString("retry_budget", "name"); err != nil {
+		return
+	}
+	if gconf.RetryBudgetRate, err = conf.FieldFloat("retry_budget", "rate"); err != nil {
+		return
+	}
+	if gconf.RetryBudgetBurst, err = conf.FieldInt("retry_budget", "burst"); err != nil {
+		return
+	}
+	if gconf.RetryBudgetName != "" && (gconf.RetryBudgetRate <= 0 || gconf.RetryBudgetBurst < 1) {
+		err = errors.New("retry_budget requires a positive rate and a burst of at least 1")
+		return
+	}
+	if gconf.ErrorsTable, err = conf.FieldString("errors_table"); err != nil {
+		return
+	}
+	var classification map[string]string
+	if classification, err = conf.FieldStringMap("error_classification"); err != nil {
+		return
+	}
+	if gconf.ErrorClassification, err = parseErrorClassification(classification); err != nil {
+		return
+	}
+	if gconf.IsolatePoisonRows, err = conf.FieldBool("isolate_poison_rows"); err != nil {
+		return
+	}
+	if gconf.AckGranularity, err = conf.FieldString("ack_granularity"); err != nil {
+		return
+	}
+	if gconf.Strict, err = conf.FieldBool("strict"); err != nil {
+		return
+	}
+	if gconf.MaxConsecutiveFailures, err = conf.FieldInt("max_consecutive_failures"); err != nil {
+		return
+	}
+	if gconf.BreakerThreshold, err = conf.FieldInt("circuit_breaker", "failure_threshold"); err != nil {
+		return
+	}
+	if gconf.BreakerCooldown, err = conf.FieldDuration("circuit_breaker", "cooldown"); err != nil {
+		return
+	}
+	if gconf.ReconnectBackoff.initialBackoff, err = conf.FieldDuration("reconnect_backoff", "initial_backoff"); err != nil {
+		return
+	}
+	if gconf.ReconnectBackoff.maxBackoff, err = conf.FieldDuration("reconnect_backoff", "max_backoff"); err != nil {
+		return
+	}
+	if gconf.ReconnectBackoff.jitter, err = conf.FieldFloat("reconnect_backoff", "jitter"); err != nil {
+		return
+	}
+	if gconf.ReconnectResetAfter, err = conf.FieldDuration("reconnect_backoff", "reset_after"); err != nil {
+		return
+	}
+	if gconf.FailoverProjectID, err = conf.FieldString("failover", "project"); err != nil {
+		return
+	}
+	if gconf.FailoverProjectID == "" {
+		gconf.FailoverProjectID = gconf.ProjectID
+	}
+	if gconf.FailoverDatasetID, err = conf.FieldString("failover", "dataset"); err != nil {
+		return
+	}
+	if gconf.FailoverDatasetID == "" {
+		gconf.FailoverDatasetID = gconf.DatasetID
+	}
+	if gconf.FailoverTableID, err = conf.FieldString("failover", "table"); err != nil {
+		return
+	}
+	if gconf.FailoverAfter, err = conf.FieldInt("failover", "after_failures"); err != nil {
+		return
+	}
+	if gconf.FailoverProbeInterval, err = conf.FieldDuration("failover", "probe_interval"); err != nil {
+		return
+	}
+	if gconf.FailoverTableID != "" && gconf.FailoverAfter < 1 {
+		err = errors.New("failover requires after_failures of at least 1")
+		return
+	}
+	if gconf.WriteRetries, err = conf.FieldBool("write_retries", "enabled"); err != nil {
+		return
+	}
+	if gconf.ConnectBackoff.Initial, err = conf.FieldDuration("write_retries", "connect_initial_backoff"); err != nil {
+		return
+	}
+	if gconf.ConnectBackoff.Max, err = conf.FieldDuration("write_retries", "connect_max_backoff"); err != nil {
+		return
+	}
+	if gconf.ConnectBackoff.Multiplier, err = conf.FieldFloat("write_retries", "connect_multiplier"); err != nil {
+		return
+	}
+	if gconf.Retry.maxAttempts < 1 || gconf.Retry.jitter < 0 || gconf.Retry.jitter > 1 {
+		err = errors.New("retry requires max_attempts of at least 1 and a jitter between 0 and 1")
+		return
+	}
+	if gconf.SlowConversionThreshold, err = conf.FieldDuration("slow_conversion_threshold"); err != nil {
+		return
+	}
+	if gconf.ErrorLogFirst, err = conf.FieldInt("error_log_sampling", "first"); err != nil {
+		return
+	}
+	if gconf.ErrorLogThereafter, err = conf.FieldInt("error_log_sampling", "thereafter"); err != nil {
+		return
+	}
+	if gconf.ErrorLogInterval, err = conf.FieldDuration("error_log_sampling", "interval"); err != nil {
+		return
+	}
+	if gconf.ErrorLogFirst > 0 && gconf.ErrorLogInterval <= 0 {
+		err = errors.New("error_log_sampling requires a positive interval")
+		return
+	}
+	if gconf.DumpDescriptor, err = conf.FieldBool("dump_descriptor"); err != nil {
+		return
+	}
+	if gconf.CostPerGiB, err = conf.FieldFloat("cost_per_gib"); err != nil {
+		return
+	}
+	if gconf.CostPerGiB < 0 {
+		err = errors.New("cost_per_gib must not be negative")
+		return
+	}
+	if gconf.TrustedSource, err = conf.FieldBool("trusted_source"); err != nil {
+		return
+	}
+	if gconf.Dedupe, err = conf.FieldBool("dedupe", "enabled"); err != nil {
+		return
+	}
+	if gconf.DedupeKeyColumns, err = conf.FieldStringList("dedupe", "key_columns"); err != nil {
 		return
 	}
 	return
 }
 
+// clientProject returns the project the clients make requests in, which is
+// the billing project when one is configured.
+func (conf gcpBigQueryOutputConfig) clientProject() string {
+	if conf.BillingProject != "" {
+		return conf.BillingProject
+	}
+	return conf.ProjectID
+}
+
 type gcpBQClientURL string
 
 func (g gcpBQClientURL) NewClient(ctx context.Context, conf gcpBigQueryOutputConfig) (*bigquery.Client, error) {
 	if g == "" {
-		var err error
-		var opt []option.ClientOption
-		opt, err = getClientOptionWithCredential(conf.CredentialsJSON, opt)
+		opt, err := clientOptions(ctx, conf)
 		if err != nil {
 			return nil, err
 		}
-		return bigquery.NewClient(ctx, conf.ProjectID)
+		if opt, err = httpClientOptions(ctx, conf, opt); err != nil {
+			return nil, err
+		}
+		return bigquery.NewClient(ctx, conf.clientProject(), opt...)
+	}
+	if conf.InsecureEndpoints {
+		return bigquery.NewClient(ctx, conf.clientProject(), option.WithoutAuthentication(), option.WithEndpoint(string(g)))
+	}
+	opt, err := clientOptions(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	if opt, err = httpClientOptions(ctx, conf, opt); err != nil {
+		return nil, err
 	}
-	return bigquery.NewClient(ctx, conf.ProjectID, option.WithoutAuthentication(), option.WithEndpoint(string(g)))
+	return bigquery.NewClient(ctx, conf.clientProject(), append(opt, option.WithEndpoint(string(g)))...)
 }
 
 type gcpMWClientURL string
 
 func (g gcpMWClientURL) NewClient(ctx context.Context, conf gcpBigQueryOutputConfig) (*managedwriter.Client, error) {
 	if g == "" {
-		var err error
-		var opt []option.ClientOption
-		opt, err = getClientOptionWithCredential(conf.CredentialsJSON, opt)
+		opt, err := clientOptions(ctx, conf)
 		if err != nil {
 			return nil, err
 		}
-		return managedwriter.NewClient(ctx, conf.ProjectID, opt...)
+		opt = grpcClientOptions(conf, opt)
+		if conf.EndpointRegion != "" {
+			opt = append(opt, option.WithEndpoint(fmt.Sprintf("%s-bigquerystorage.googleapis.com:443", conf.EndpointRegion)))
+		}
+		if conf.KeepaliveTime > 0 {
+			opt = append(opt, option.WithGRPCDialOption(grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                conf.KeepaliveTime,
+				Timeout:             conf.KeepaliveTimeout,
+				PermitWithoutStream: conf.KeepalivePermitWithout,
+			})))
+		}
+		if conf.ConnectionPoolSize > 0 {
+			opt = append(opt, option.WithGRPCConnectionPool(conf.ConnectionPoolSize))
+		}
+		if conf.ConnectionPoolSize > 1 {
+			opt = append(opt,
+				managedwriter.WithMultiplexing(),
+				managedwriter.WithMultiplexPoolLimit(conf.ConnectionPoolSize),
+			)
+		}
+		return managedwriter.NewClient(ctx, conf.clientProject(), opt...)
+	}
+	if conf.InsecureEndpoints {
+		return managedwriter.NewClient(ctx,
+			conf.clientProject(),
+			option.WithoutAuthentication(),
+			option.WithEndpoint(string(g)),
+			option.WithGRPCDialOption(grpc.WithInsecure()),
+		)
 	}
-	return managedwriter.NewClient(ctx,
-		conf.ProjectID,
-		option.WithoutAuthentication(),
-		option.WithEndpoint(string(g)),
-		option.WithGRPCDialOption(grpc.WithInsecure()),
-	)
+	opt, err := clientOptions(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	opt = grpcClientOptions(conf, opt)
+	return managedwriter.NewClient(ctx, conf.clientProject(), append(opt, option.WithEndpoint(string(g)))...)
 }
 
-func getClientOptionWithCredential(credentialsJSON string, opt []option.ClientOption) ([]option.ClientOption, error) {
-	if len(credentialsJSON) > 0 {
-		opt = append(opt, option.WithCredentialsJSON([]byte(credentialsJSON)))
+// clientOptions returns the options shared by the BigQuery and managed writer
+// clients, so that both authenticate the same way.
+func clientOptions(ctx context.Context, conf gcpBigQueryOutputConfig) ([]option.ClientOption, error) {
+	opt, err := getClientOptionWithCredential(conf, nil)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Token.enabled() {
+		opt = append(opt, option.WithTokenSource(conf.Token.tokenSource()))
+	}
+	if conf.ImpersonateServiceAccount != "" {
+		scopes := conf.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{bigquery.Scope}
+		}
+		// The configured credentials become the base identity that is used to
+		// obtain tokens of the impersonated account.
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: conf.ImpersonateServiceAccount,
+			Scopes:          scopes,
+			Delegates:       conf.ImpersonateDelegates,
+		}, opt...)
+		if err != nil {
+			return nil, fmt.Errorf("error impersonating service account %v: %w", conf.ImpersonateServiceAccount, err)
+		}
+		opt = []option.ClientOption{option.WithTokenSource(ts)}
+	}
+	if len(conf.Scopes) > 0 && conf.ImpersonateServiceAccount == "" {
+		opt = append(opt, option.WithScopes(conf.Scopes...))
+	}
+	if conf.QuotaProject != "" {
+		opt = append(opt, option.WithQuotaProject(conf.QuotaProject))
+	}
+	opt = append(opt, option.WithUserAgent(userAgent(conf.UserAgent)))
+	return append(opt, conf.clientOptions...), nil
+}
+
+func getClientOptionWithCredential(conf gcpBigQueryOutputConfig, opt []option.ClientOption) ([]option.ClientOption, error) {
+	creds, err := conf.resolveCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) > 0 {
+		opt = append(opt, option.WithCredentialsJSON(creds))
 	}
 	return opt, nil
 }
@@ -141,6 +730,7 @@ And:
 
 ` + service.OutputPerformanceDocs(true, true)).
 		Field(service.NewStringField("project").Description("The project ID of the dataset to insert data to. If not set, it will be inferred from the credentials or read from the GOOGLE_CLOUD_PROJECT environment variable.").Default("")).
+		Field(service.NewStringField("billing_project").Description("The project that API requests are made in and billed to, when it differs from the project of the table, such as for cross-project writes in a data mesh. Defaults to `project`.").Advanced().Default("")).
 		Field(service.NewStringField("dataset").Description("The BigQuery Dataset ID.")).
 		Field(service.NewStringField("table").Description("The table to insert messages to.")).
 		Field(service.NewBoolField("allow_partial").
@@ -152,7 +742,428 @@ And:
 		Field(service.NewIntField("max_in_flight").
 			Description("The maximum number of message batches to have in flight at a given time. Increase this to improve throughput.").
 			Default(64)). // TODO: Tune this default
-		Field(service.NewStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json.").Secret().Default("")).
+		Field(service.NewInterpolatedStringField("credentials_json").Description("An optional field to set Google Service Account Credentials json. Interpolations such as `${! file(\"/var/secrets/key.json\") }` are resolved again whenever the output reconnects, so that rotated keys take effect without a restart.").Secret().Default("")).
+		Field(service.NewStringField("credentials_file").Description("An optional path to a Google credentials json file, such as a mounted secret, which overrides `GOOGLE_APPLICATION_CREDENTIALS` for this output. Any file accepted by Application Default Credentials may be used. Cannot be combined with `credentials_json`.").Default("")).
+		Field(service.NewObjectField("token",
+			service.NewStringField("value").
+				Description("A static access token. It is not refreshed, so it is only suitable for short-lived pipelines.").
+				Secret().
+				Default(""),
+			service.NewStringListField("command").
+				Description("A command printing an access token to stdout, either as the plain token or as JSON with `access_token` and `expires_in`, which is run again when the token expires.").
+				Example([]string{"gcloud", "auth", "print-access-token"}).
+				Default([]any{}),
+			service.NewStringField("url").
+				Description("A URL returning an access token in the format of the GCE metadata server, such as a token broker sidecar, which is fetched again when the token expires.").
+				Example("http://localhost:8080/computeMetadata/v1/instance/service-accounts/default/token").
+				Default(""),
+			service.NewDurationField("refresh_interval").
+				Description("How long a token obtained from the command or URL is used when its expiry is unknown.").
+				Default("30m"),
+		).
+			Description("Authenticate with OAuth access tokens minted elsewhere, such as by a central token broker, instead of credentials. Only one of `value`, `command` and `url` may be set.").
+			Advanced()).
+		Field(service.NewObjectField("impersonate_service_account",
+			service.NewStringField("target").
+				Description("The email address of the service account to impersonate. Leave empty to use the base credentials directly.").
+				Default(""),
+			service.NewStringListField("delegates").
+				Description("The service accounts of a delegation chain, each of which must be granted `roles/iam.serviceAccountTokenCreator` on the next account in the chain, and the last one on the target.").
+				Default([]any{}),
+		).
+			Description("Impersonate a service account using the base credentials, which are the configured credentials or Application Default Credentials. The base identity needs `roles/iam.serviceAccountTokenCreator` on the target account, or on the first delegate.").
+			Advanced()).
+		Field(service.NewStringField("quota_project").
+			Description("The project that API quota and billing of requests are attributed to, when it differs from the project of the table. The credentials need `serviceusage.services.use` on the quota project. Leave empty to attribute requests to the project of the credentials.").
+			Advanced().
+			Default("")).
+		Field(service.NewStringField("endpoint_region").
+			Description("A region whose regional Storage Write API endpoint, e.g. `us-central1-bigquerystorage.googleapis.com`, appends are sent to instead of the global endpoint, for data residency or lower latency. The table must be located in that region. Leave empty to use the global endpoint.").
+			Example("us-central1").
+			Advanced().
+			Default("")).
+		Field(service.NewStringField("http_endpoint").
+			Description("Overrides the endpoint of the BigQuery API used to read table metadata, such as to point at the BigQuery emulator or a test double.").
+			Example("http://localhost:9050").
+			Advanced().
+			Default("")).
+		Field(service.NewStringField("grpc_endpoint").
+			Description("Overrides the endpoint of the Storage Write API, such as to point at the BigQuery emulator or a test double.").
+			Example("localhost:9060").
+			Advanced().
+			Default("")).
+		Field(service.NewBoolField("insecure").
+			Description("Connect to `http_endpoint` and `grpc_endpoint` without credentials and, for gRPC, without TLS, as emulators expect.").
+			Advanced().
+			Default(false)).
+		Field(service.NewTLSToggledField("tls").
+			Description("Custom TLS settings for both clients, such as a private CA bundle for TLS intercepting proxies or Private Service Connect endpoints, or a client certificate.").
+			Advanced()).
+		Field(service.NewStringField("user_agent").
+			Description("A suffix appended to the user agent of both clients, which shows up in GCP audit logs and helps support identify the traffic of a pipeline. The user agent always identifies the plugin and its version.").
+			Example("orders-pipeline/1.4.0").
+			Advanced().
+			Default("")).
+		Field(service.NewObjectField("proxy",
+			service.NewStringField("url").
+				Description("The URL of an HTTP proxy that both clients connect through, tunnelling gRPC with `CONNECT`. Leave empty to use the proxy of the `HTTPS_PROXY` environment variable, if any.").
+				Example("http://proxy.internal:3128").
+				Default(""),
+			service.NewStringField("username").
+				Description("The username to authenticate to the proxy with.").
+				Default(""),
+			service.NewStringField("password").
+				Description("The password to authenticate to the proxy with.").
+				Secret().
+				Default(""),
+		).
+			Description("An explicit outbound proxy for the BigQuery API and Storage Write API connections.").
+			Advanced()).
+		Field(service.NewStringListField("scopes").
+			Description("The OAuth scopes requested for the credentials, such as when tokens are brokered through a proxy that requires specific scopes. Leave empty to use the default scopes of the BigQuery APIs.").
+			Example([]string{"https://www.googleapis.com/auth/bigquery", "https://www.googleapis.com/auth/cloud-platform"}).
+			Advanced().
+			Default([]any{})).
+		Field(service.NewBoolField("promote_to_repeated").
+			Description("Wrap a single value into a one-element array when the destination column is REPEATED, instead of rejecting the message.").
+			Advanced().
+			Default(false)).
+		Field(service.NewBoolField("drop_null_fields").
+			Description("Remove fields with an explicit JSON `null` value before conversion. Combined with `missing_value_interpretation: DEFAULT_VALUE` this lets column defaults apply instead of writing NULLs.").
+			Advanced().
+			Default(false)).
+		Field(service.NewStringAnnotatedEnumField("missing_value_interpretation", map[string]string{
+			"NULL_VALUE":    "Fields missing from a row are written as NULL.",
+			"DEFAULT_VALUE": "Fields missing from a row are written with the column default value, or NULL when the column has no default.",
+		}).
+			Description("How BigQuery interprets columns that are missing from an appended row.").
+			Advanced().
+			Default("NULL_VALUE")).
+		Field(service.NewIntField("max_row_bytes").
+			Description("The maximum size in bytes of a single serialized row. Rows exceeding it are handled according to `row_size_action` before the batch is appended, rather than failing the whole append request.").
+			Advanced().
+			Default(defaultMaxRowBytes)).
+		Field(service.NewStringAnnotatedEnumField("row_size_action", map[string]string{
+			rowSizeActionReject:   "Fail the row so that it can be handled by error handling patterns such as a `fallback` output.",
+			rowSizeActionDrop:     "Log and discard the row, acknowledging it without writing.",
+			rowSizeActionTruncate: "Shorten the STRING or BYTES columns listed in `truncate_columns` until the row fits, rejecting it when it still does not.",
+		}).
+			Description("The action to take when a row exceeds `max_row_bytes`.").
+			Advanced().
+			Default(rowSizeActionReject)).
+		Field(service.NewStringListField("truncate_columns").
+			Description("Top level STRING or BYTES columns that may be shortened, in order, when `row_size_action` is `truncate`.").
+			Advanced().
+			Default([]any{})).
+		Field(service.NewIntField("max_message_bytes").
+			Description("The maximum size in bytes of a raw message, checked before conversion. Larger messages are rejected. Set to `0` to disable the check.").
+			Advanced().
+			Default(0)).
+		Field(service.NewIntField("max_json_depth").
+			Description("The maximum nesting depth of objects and arrays within a message, checked before conversion. Deeper messages are rejected. Set to `0` to disable the check.").
+			Advanced().
+			Default(0)).
+		Field(service.NewStringField("default_timezone").
+			Description("When set, date-time strings written to TIMESTAMP columns are converted to the epoch microseconds expected by the Write API, and strings without zone information are interpreted in this IANA time zone. When empty, TIMESTAMP columns only accept epoch microseconds.").
+			Examples("UTC", "Europe/Berlin", "America/New_York").
+			Advanced().
+			Default("")).
+		Field(service.NewBoolField("decimal_comma").
+			Description("Accept numbers formatted with `.` as the grouping separator and `,` as the decimal separator, e.g. `\"1.234,56\"`, as strings for NUMERIC, BIGNUMERIC, FLOAT and INTEGER columns. Strings in any other format, including `\"12.5\"`, are rejected. NUMERIC and BIGNUMERIC strings are encoded into the binary form expected by the Write API.").
+			Advanced().
+			Default(false)).
+		Field(service.NewStringField("raw_json_column").
+			Description("When set, the whole message body is written as is into this JSON or STRING column instead of being mapped onto the table columns. Useful for landing-zone tables where parsing is deferred to SQL.").
+			Advanced().
+			Default("")).
+		Field(service.NewStringMapField("raw_json_metadata_columns").
+			Description("A map of column names to metadata keys whose values are written alongside the `raw_json_column`. Columns must be of type JSON or STRING.").
+			Example(map[string]any{"source_topic": "kafka_topic", "source_key": "kafka_key"}).
+			Advanced().
+			Default(map[string]any{})).
+		Field(service.NewBoolField("fast_encoding").
+			Description("Encode messages straight into proto wire format using a field table derived from the table schema, bypassing the generic protojson conversion. Tables with field types the fast encoder does not support automatically use the generic conversion.").
+			Advanced().
+			Default(true)).
+		Field(service.NewIntField("max_rows_per_append").
+			Description("The maximum number of rows sent in a single append request, batches with more rows are split into consecutive appends. Set to `0` for no limit.").
+			Advanced().
+			Default(0)).
+		Field(service.NewIntField("max_bytes_per_append").
+			Description("The maximum number of bytes of row data sent in a single append request, batches exceeding it are split into consecutive appends. Cannot exceed the default, which leaves headroom below the 10MB request limit.").
+			Advanced().
+			Default(maxAppendBytes)).
+		Field(service.NewIntField("max_inflight_requests").
+			Description("The maximum number of append requests the managed stream allows in flight before applying backpressure. Set to `0` to use the client library default.").
+			Advanced().
+			Default(0)).
+		Field(service.NewIntField("max_inflight_bytes").
+			Description("The maximum number of request bytes the managed stream allows in flight before applying backpressure. Set to `0` to use the client library default.").
+			Advanced().
+			Default(0)).
+		Field(service.NewObjectField("adaptive_append",
+			service.NewBoolField("enabled").
+				Description("Whether to adapt the number of rows per append to observed append latency and errors. When enabled this takes precedence over `max_rows_per_append`.").
+				Default(false),
+			service.NewIntField("min_rows").
+				Description("The lower bound of rows per append.").
+				Default(100),
+			service.NewIntField("max_rows").
+				Description("The upper bound of rows per append.").
+				Default(10000),
+			service.NewDurationField("target_latency").
+				Description("Appends slower than this shrink the number of rows per append, faster ones grow it.").
+				Default("1s"),
+		).
+			Description("Grow and shrink the effective append size based on observed append latency, keeping throughput high without tuning per table.").
+			Advanced()).
+		Field(service.NewObjectField("keepalive",
+			service.NewDurationField("time").
+				Description("The interval after which a keepalive ping is sent on an idle gRPC connection. Set to `0s` to disable keepalive pings.").
+				Default("0s"),
+			service.NewDurationField("timeout").
+				Description("How long to wait for a keepalive ping to be acknowledged before the connection is closed.").
+				Default("20s"),
+			service.NewBoolField("permit_without_stream").
+				Description("Whether to send keepalive pings even when there are no active streams.").
+				Default(false),
+		).
+			Description("gRPC keepalive settings of the write connection, useful to keep connections through NAT gateways and firewalls with idle timeouts alive.").
+			Advanced()).
+		Field(service.NewIntField("connection_pool_size").
+			Description("The number of gRPC connections opened by the managed writer client. When greater than one, appends to the default stream are multiplexed across up to this many connections so that a single hot pipeline is not limited by the flow control window of one HTTP/2 connection. Set to `0` to use the client library default of a single append connection.").
+			Advanced().
+			Default(0)).
+		Field(service.NewIntField("result_queue_size").
+			Description("When greater than zero, appends are sent without waiting for the result of the previous append and their results are confirmed by a separate collector, keeping the write stream saturated. This sets how many unconfirmed appends may be queued before writers are blocked. Batches are still acknowledged only once all of their rows are confirmed, and only the rows of failed appends are reported as failed. Set to `0` to confirm each append before sending the next.").
+			Advanced().
+			Default(0)).
+		Field(service.NewDurationField("append_timeout").
+			Description("The maximum time an append may take to be sent, including waiting for flow control and retries made by the client. Set to `0s` for no limit.").
+			Advanced().
+			Default("0s")).
+		Field(service.NewDurationField("result_timeout").
+			Description("The maximum time to wait for the result of an append. An append that times out is retried after reconnecting like any other `DEADLINE_EXCEEDED` error. Set to `0s` to wait until the pipeline cancels the write.").
+			Advanced().
+			Default("0s")).
+		Field(service.NewDurationField("batch_deadline").
+			Description("The maximum time to spend writing a batch, covering conversion, appends, confirmation and all retries. A batch that is not written in time is nacked as a whole so that it is redelivered, which bounds the worst case end-to-end latency. A reconnect started by a batch that runs out of time carries on in the background. Set to `0s` for no limit.").
+			Advanced().
+			Default("0s")).
+		Field(service.NewObjectField("spill",
+			service.NewStringField("path").
+				Description("A directory to spill rows to when appends fail because BigQuery is unavailable or throttling. Spilled rows are acknowledged immediately and replayed in the background once appends succeed again, including after a restart. Rows spilled with a table schema other than the current one are not replayed, their files are kept with a `.mismatch` suffix instead. Leave empty to disable spilling.").
+				Default(""),
+			service.NewIntField("max_bytes").
+				Description("The maximum size of spilled rows kept on disk. Once reached, failed appends are reported as errors again and backpressure resumes. Set to `0` for no limit.").
+				Default(1024*1024*1024),
+			service.NewDurationField("replay_interval").
+				Description("How often replay of spilled rows is attempted.").
+				Default("5s"),
+		).
+			Description("A disk-backed buffer that absorbs short BigQuery outages and quota throttling without propagating backpressure upstream. Replayed rows are written after rows appended since, so ordering is not preserved.").
+			Advanced()).
+		Field(service.NewBoolField("skip_existence_check").
+			Description("Skip reading the dataset and table metadata when connecting and read the table schema from the default write stream instead. This avoids the `bigquery.tables.get` permission and reduces metadata API calls for configs with many outputs, at the cost of less specific errors when the dataset or table does not exist.").
+			Advanced().
+			Default(false)).
+		Field(service.NewDurationField("liveness_interval").
+			Description("When nothing was appended for this long, check that the managed stream is still alive with a `GetWriteStream` call, and replace it when it is not, so that streams that died while idle are replaced before the next batch. Set to `0s` to disable probing.").
+			Advanced().
+			Default("0s")).
+		Field(service.NewDurationField("heartbeat_interval").
+			Description("Log the rows and bytes appended per second, the share of messages that failed and the number of batches and appends in flight at info level every interval. Set to `0s` to disable the heartbeat.").
+			Advanced().
+			Default("0s")).
+		Field(service.NewObjectField("lifecycle_webhook",
+			service.NewStringField("url").
+				Description("The URL that lifecycle events are posted to as JSON. Leave empty to disable the webhook.").
+				Example("https://automation.internal/hooks/bigquery").
+				Default(""),
+			service.NewStringListField("events").
+				Description("The events to post, any of `reconnect`, `breaker_open`, `breaker_close`, `schema_refresh` and `batch_failed`.").
+				Default([]any{"reconnect", "breaker_open", "breaker_close", "schema_refresh", "batch_failed"}),
+			service.NewDurationField("timeout").
+				Description("The timeout of each post.").
+				Default("5s"),
+		).
+			Description("Post lifecycle events of the output to an HTTP endpoint, so that incident automation can react to them without scraping logs. Events are posted in the background and dropped when the endpoint cannot keep up, they never block writes.").
+			Advanced()).
+		Field(service.NewBoolField("permission_check").
+			Description("Verify on connect that the credentials hold the permissions needed on the destination table, `bigquery.tables.updateData` and, unless `skip_existence_check` is set, `bigquery.tables.get`, and fail with the missing permissions otherwise.").
+			Advanced().
+			Default(false)).
+		Field(service.NewStringField("schema_cache").
+			Description("An optional cache resource used to share table schemas between outputs, so that configs with many outputs against the same tables fetch each schema once. Converted descriptors are always shared between outputs of the same process writing to tables with identical schemas.").
+			Advanced().
+			Default("")).
+		Field(service.NewDurationField("schema_cache_ttl").
+			Description("How long schemas are kept in the `schema_cache`. Set to `0s` to use the default TTL of the cache.").
+			Advanced().
+			Default("10m")).
+		Field(service.NewObjectField("retry",
+			service.NewIntField("max_attempts").
+				Description("The maximum number of attempts of an append, including the first. Set to `1` to disable retries.").
+				Default(3),
+			service.NewDurationField("initial_backoff").
+				Description("The delay before the first retry, which doubles with every further retry.").
+				Default("1s"),
+			service.NewDurationField("max_backoff").
+				Description("The maximum delay between retries.").
+				Default("30s"),
+			service.NewFloatField("jitter").
+				Description("The fraction by which each delay is randomly increased or decreased, between `0` and `1`.").
+				Default(0.0),
+			service.NewDurationField("max_elapsed").
+				Description("The total time budget for retrying an append, after which the last error is returned. Set to `0s` for no budget.").
+				Default("0s"),
+			service.NewDurationField("quota_initial_backoff").
+				Description("The delay before retrying an append rejected with `RESOURCE_EXHAUSTED` when the error does not specify a retry delay, which doubles with every further retry.").
+				Default("5s"),
+			service.NewDurationField("quota_max_backoff").
+				Description("The maximum delay between retries of appends rejected with `RESOURCE_EXHAUSTED`.").
+				Default("60s"),
+		).
+			Description("Controls how failed appends are retried. Before retrying an append that failed with a connection error the output waits for the backoff delay and reconnects the stream. Appends that exceeded a quota are retried on the same stream after the delay requested by BigQuery, or the quota backoff when none is given, and counted in the `bigquery_stream_quota_exceeded` metric.").
+			Advanced()).
+		Field(service.NewObjectField("retry_budget",
+			service.NewStringField("name").
+				Description("The name of the retry budget. Outputs using the same name share a single budget. Leave empty to disable the budget.").
+				Default(""),
+			service.NewFloatField("rate").
+				Description("The number of retries per second the budget replenishes.").
+				Default(10.0),
+			service.NewIntField("burst").
+				Description("The maximum number of retries the budget can accumulate.").
+				Default(50),
+		).
+			Description("A retry budget shared by every `gcp_bigquery_stream` output configured with the same name, so that an incident affecting a whole project does not see each output retrying at full rate against the same quota. Once the budget is exhausted failed appends are not retried and their error is returned. The settings of the first output created take effect for the whole budget.").
+			Advanced()).
+		Field(service.NewStringField("errors_table").
+			Description("An optional table in the same dataset to write permanently rejected rows to, such as rows failing conversion or rejected by BigQuery, instead of failing them. Rows are written to the columns `payload` (STRING or JSON), `error` (STRING), `destination` (STRING), `failed_at` (TIMESTAMP) and `metadata` (STRING or JSON), and columns missing from the table are left out. Payloads larger than 1MiB are truncated. Rows are only failed when they cannot be written to the errors table.").
+			Advanced().
+			Default("")).
+		Field(service.NewStringMapField("error_classification").
+			Description("Overrides how append errors are handled, keyed by gRPC code (e.g. `INTERNAL`) or Storage Write API error code (e.g. `SCHEMA_MISMATCH_EXTRA_FIELDS`). Errors classed as `retryable` are retried on the same stream after a backoff, `reconnectable` errors are retried after reconnecting the stream, and the rows of `fatal` errors are rejected without retrying. Storage error codes take precedence over gRPC codes.").
+			Example(map[string]any{"INTERNAL": "retryable", "FAILED_PRECONDITION": "fatal"}).
+			Advanced().
+			Default(map[string]any{})).
+		Field(service.NewBoolField("isolate_poison_rows").
+			Description("When an append fails with a fatal error that does not identify the offending rows, bisect the append and retry the halves until the failing rows are isolated, so that only those are rejected and the rest are written. This costs additional appends for every failing row.").
+			Advanced().
+			Default(false)).
+		Field(service.NewStringEnumField("ack_granularity", "row", "batch").
+			Description("Whether a failed row nacks only itself (`row`) or the whole batch (`batch`). Use `batch` for inputs that can only replay whole offset ranges; rows of the batch that were already written are written again when the batch is retried, and rejected rows are not routed to the errors table.").
+			Advanced().
+			Default("row")).
+		Field(service.NewBoolField("strict").
+			Description("Convert and validate the whole batch before appending any of it, and fail the entire batch without writing a single row when any message is invalid. Use this when downstream consumers cannot tolerate a batch being partially visible. Invalid messages are still annotated with error metadata, but are not routed to the errors table.").
+			Advanced().
+			Default(false)).
+		Field(service.NewIntField("max_consecutive_failures").
+			Description("The number of consecutive failed appends after which the output is closed and the process exits with a non-zero status, for deployments where a crash and restart by the orchestrator is preferable to retrying indefinitely. Rejected rows and appends abandoned because a batch was cancelled or ran out of time do not count as failures. Set to `0` to never exit.").
+			Advanced().
+			Default(0)).
+		Field(service.NewObjectField("circuit_breaker",
+			service.NewIntField("failure_threshold").
+				Description("The number of consecutive failed appends after which the breaker opens. Set to `0` to disable the breaker.").
+				Default(0),
+			service.NewDurationField("cooldown").
+				Description("How long the breaker stays open before a single probe append is let through. A successful probe closes the breaker, a failed one opens it again.").
+				Default("30s"),
+		).
+			Description("A circuit breaker that prevents retry storms during BigQuery incidents. While open, writes fail fast as not connected so that pipeline level retries and fallbacks apply, and reconnection attempts are rejected until the cooldown has passed.").
+			Advanced()).
+		Field(service.NewObjectField("reconnect_backoff",
+			service.NewDurationField("initial_backoff").
+				Description("The delay before the second reconnect within the reset window, which doubles with every further reconnect. The first reconnect happens immediately.").
+				Default("1s"),
+			service.NewDurationField("max_backoff").
+				Description("The maximum delay before a reconnect.").
+				Default("60s"),
+			service.NewFloatField("jitter").
+				Description("The fraction by which each delay is randomly increased or decreased, between `0` and `1`.").
+				Default(0.2),
+			service.NewDurationField("reset_after").
+				Description("Reconnects that happen longer than this after the previous one start from an immediate reconnect again.").
+				Default("5m"),
+		).
+			Description("Controls the delay before the managed stream is recreated, so that a flapping endpoint is not hammered with reconnects while one-off blips still recover quickly.").
+			Advanced()).
+		Field(service.NewObjectField("failover",
+			service.NewStringField("project").
+				Description("The project of the failover table. Defaults to the project of the output.").
+				Default(""),
+			service.NewStringField("dataset").
+				Description("The dataset of the failover table. Defaults to the dataset of the output.").
+				Default(""),
+			service.NewStringField("table").
+				Description("The failover table. Leave empty to disable failover.").
+				Default(""),
+			service.NewIntField("after_failures").
+				Description("The number of consecutive failed appends to the primary table after which the output fails over.").
+				Default(5),
+			service.NewDurationField("probe_interval").
+				Description("How often an append is sent to the primary table while failed over, to detect its recovery.").
+				Default("30s"),
+		).
+			Description("A secondary table, which may be in another dataset, project or region, that rows are written to after sustained failures of the primary table. The output switches back once a probe append to the primary table succeeds. Rows are encoded for the primary table, so the failover table must have a compatible schema, and is written with the same credentials.").
+			Advanced()).
+		Field(service.NewObjectField("write_retries",
+			service.NewBoolField("enabled").
+				Description("Whether the managed writer retries failed appends itself. Transient stream errors are then retried inside the client library, up to four attempts per append, before they reach the `retry` policy of the output.").
+				Default(false),
+			service.NewDurationField("connect_initial_backoff").
+				Description("The initial delay between attempts to open the append connection when it is unavailable.").
+				Default("100ms"),
+			service.NewDurationField("connect_max_backoff").
+				Description("The maximum delay between attempts to open the append connection.").
+				Default("10s"),
+			service.NewFloatField("connect_multiplier").
+				Description("The factor by which the delay between attempts to open the append connection grows.").
+				Default(1.3),
+		).
+			Description("Retry support built into the managed writer. Retried appends may be written more than once, which matches the at-least-once semantics of the default stream.").
+			Advanced()).
+		Field(service.NewDurationField("slow_conversion_threshold").
+			Description("Log a warning when converting a batch into proto rows takes longer than this, which points at CPU-bound conversion rather than BigQuery latency. Set to `0s` to disable the warning. Conversion and append latencies are always recorded in the `bigquery_stream_conversion_latency_ns` and `bigquery_stream_append_latency_ns` metrics.").
+			Advanced().
+			Default("0s")).
+		Field(service.NewObjectField("error_log_sampling",
+			service.NewIntField("first").
+				Description("The number of lines of each kind of row error logged per interval before sampling starts. Set to `0` to log every row error.").
+				Default(0),
+			service.NewIntField("thereafter").
+				Description("Once sampling started, log only every this many lines of each kind of row error, along with the number of lines suppressed. Set to `0` to suppress all further lines until the interval ends.").
+				Default(100),
+			service.NewDurationField("interval").
+				Description("The period after which the counts of all kinds of row errors are reset.").
+				Default("1m"),
+		).
+			Description("Sample row level error logs, such as conversion failures and dropped rows, so that a misbehaving producer does not flood the logs with identical lines. Errors are grouped by their message with quoted and numeric values masked. Metrics still count every error.").
+			Advanced()).
+		Field(service.NewBoolField("dump_descriptor").
+			Description("Log the normalized storage schema of the table and the proto descriptor derived from it at info level whenever the schema is loaded. Useful to diagnose why a field is dropped or rejected.").
+			Advanced().
+			Default(false)).
+		Field(service.NewFloatField("cost_per_gib").
+			Description("The price per GiB of appended data used for the `bigquery_stream_estimated_cost` metric, in the currency of your billing account. The estimate ignores the monthly free tier and is based on the serialized row size, so treat it as an approximation of the Storage Write API bill. Set to `0` to disable the metric.").
+			Advanced().
+			Default(0.025)).
+		Field(service.NewBoolField("trusted_source").
+			Description("Skip defensive per-row validation for pipelines where upstream already guarantees that messages conform to the table schema. This disables `max_message_bytes` and `max_json_depth`, ignores unknown fields and does not check required fields for presence, saving CPU on high volume streams. Rows that do not conform are rejected by BigQuery rather than by the output, which fails the whole append.").
+			Advanced().
+			Default(false)).
+		Field(service.NewObjectField("dedupe",
+			service.NewBoolField("enabled").
+				Description("Whether duplicate rows within a batch are dropped before appending. Dropped duplicates are acknowledged as written.").
+				Default(false),
+			service.NewStringListField("key_columns").
+				Description("Columns whose values identify duplicate rows. When empty, only byte-identical messages are considered duplicates.").
+				Default([]any{}),
+		).
+			Description("Suppresses duplicate rows within a batch, for upstream sources known to emit duplicates in bursts. Duplicates across batches are not detected.").
+			Advanced()).
 		Field(service.NewBatchPolicyField("batching"))
 }
 
@@ -170,7 +1181,7 @@ func init() {
 			if gconf, err = gcpBigQueryOutputConfigFromParsed(conf); err != nil {
 				return
 			}
-			output, err = newGCPBigQueryOutput(gconf, mgr.Logger())
+			output, err = newGCPBigQueryOutput(gconf, mgr)
 			return
 		})
 	if err != nil {
@@ -187,35 +1198,223 @@ type gcpBigQueryOutput struct {
 	mwClient *managedwriter.Client
 	connMut  sync.RWMutex
 
+	// reconnecting is non-nil while a reconnect is in flight and is closed
+	// once it completes, with the outcome stored in reconnectErr.
+	reconnecting chan struct{}
+	reconnectErr error
+
+	// reconnectAttempts counts reconnects since the last one that was more
+	// than reconnect_backoff.reset_after ago.
+	reconnectAttempts int
+	lastReconnect     time.Time
+
+	// credentials fingerprints the credentials the clients were created
+	// with.
+	credentials string
+
 	managedStream     *managedwriter.ManagedStream
 	messageDescriptor protoreflect.MessageDescriptor
+	messagePool       *messagePool
 	descriptorProto   *descriptorpb.DescriptorProto
-
-	umo *protojson.UnmarshalOptions
-
-	log *service.Logger
+	schemaFields      map[string]*schemaField
+	encoder           *fastEncoder
+
+	umo         *protojson.UnmarshalOptions
+	mo          proto.MarshalOptions
+	transformer *rowTransformer
+
+	// shutdownCtx is cancelled on Close, aborting in-flight writes and their
+	// retry and reconnect delays.
+	shutdownCtx context.Context
+	shutdown    context.CancelFunc
+
+	adaptive    *adaptiveChunker
+	breaker     *circuitBreaker
+	retryBudget *retryBudget
+	failover    *failoverDestination
+	deduper     *rowDeduper
+	collector   *resultCollector
+	spill       *spillQueue
+	errorSink   *errorSink
+	logSampler  *logSampler
+	throughput  throughputCounters
+	webhook     *webhook
+
+	mAppendSplits      *service.MetricCounter
+	mConversionLatency *service.MetricTimer
+	mAppendLatency     *service.MetricTimer
+	mDuplicates        *service.MetricCounter
+	mQuotaExceeded     *service.MetricCounter
+	mRowsAppended      *service.MetricCounter
+	mBytesAppended     *service.MetricCounter
+	mConversionErrors  *service.MetricCounter
+	mRetries           *service.MetricCounter
+	mReconnects        *service.MetricCounter
+	mSchemaRefreshes   *service.MetricCounter
+	mSchemaUpdates     *service.MetricCounter
+	mUnknownFields     *service.MetricCounter
+	mRowsRejected      *service.MetricCounter
+	mState             *service.MetricGauge
+	mCost              *service.MetricCounter
+	stateMut           sync.Mutex
+
+	consecutiveFailures atomic.Int64
+	// dest is the destination label of metrics and events, set once the
+	// project of the table is known.
+	dest atomic.Pointer[string]
+	// exiting is set once max_consecutive_failures is reached.
+	exiting atomic.Bool
+
+	// lastAppend is the time of the last append in Unix nanoseconds.
+	lastAppend atomic.Int64
+
+	mgr    *service.Resources
+	log    *service.Logger
+	tracer trace.Tracer
 }
 
 func newGCPBigQueryOutput(
 	conf gcpBigQueryOutputConfig,
-	log *service.Logger,
+	mgr *service.Resources,
 ) (*gcpBigQueryOutput, error) {
 	g := &gcpBigQueryOutput{
-		conf: conf,
-		log:  log,
+		conf:        conf,
+		mgr:         mgr,
+		log:         mgr.Logger(),
+		tracer:      mgr.OtelTracer().Tracer("gcp_bigquery_stream"),
+		clientURL:   gcpBQClientURL(conf.HTTPEndpoint),
+		mwClientURL: gcpMWClientURL(conf.GRPCEndpoint),
 		umo: &protojson.UnmarshalOptions{
-			AllowPartial:   conf.AllowPartial,
-			DiscardUnknown: conf.DiscardUnknown,
+			AllowPartial:   conf.AllowPartial || conf.TrustedSource,
+			DiscardUnknown: conf.DiscardUnknown || conf.TrustedSource,
+		},
+		mo: proto.MarshalOptions{
+			AllowPartial: conf.TrustedSource,
 		},
+		transformer: newRowTransformer(conf),
+		logSampler:  newLogSampler(conf.ErrorLogFirst, conf.ErrorLogThereafter, conf.ErrorLogInterval),
+
+		mAppendSplits:      mgr.Metrics().NewCounter("bigquery_stream_append_splits"),
+		mConversionLatency: mgr.Metrics().NewTimer("bigquery_stream_conversion_latency_ns"),
+		mAppendLatency:     mgr.Metrics().NewTimer("bigquery_stream_append_latency_ns"),
+		mDuplicates:        mgr.Metrics().NewCounter("bigquery_stream_duplicates_dropped"),
+		mQuotaExceeded:     mgr.Metrics().NewCounter("bigquery_stream_quota_exceeded", "dimension"),
+		mRowsAppended:      mgr.Metrics().NewCounter("bigquery_stream_rows_appended"),
+		mBytesAppended:     mgr.Metrics().NewCounter("bigquery_stream_bytes_appended"),
+		mConversionErrors:  mgr.Metrics().NewCounter("bigquery_stream_conversion_errors"),
+		mRetries:           mgr.Metrics().NewCounter("bigquery_stream_retries"),
+		mReconnects:        mgr.Metrics().NewCounter("bigquery_stream_reconnects"),
+		mSchemaRefreshes:   mgr.Metrics().NewCounter("bigquery_stream_schema_refreshes"),
+		mSchemaUpdates:     mgr.Metrics().NewCounter("bigquery_stream_schema_updates"),
+		mUnknownFields:     mgr.Metrics().NewCounter("bigquery_stream_unknown_fields_discarded"),
+		mRowsRejected:      mgr.Metrics().NewCounter("bigquery_stream_rows_rejected", "category"),
+		mState:             mgr.Metrics().NewGauge("bigquery_stream_state", "destination", "state"),
+		mCost:              mgr.Metrics().NewCounter("bigquery_stream_estimated_cost", "destination"),
+	}
+	g.shutdownCtx, g.shutdown = context.WithCancel(context.Background())
+	if conf.ProjectID != bigquery.DetectProjectID {
+		g.resolveDestination()
+	}
+	if conf.LivenessInterval > 0 {
+		go g.probeLiveness(conf.LivenessInterval)
+	}
+	if conf.HeartbeatInterval > 0 {
+		go g.heartbeat(conf.HeartbeatInterval)
+	}
+	if conf.WebhookURL != "" {
+		g.webhook = newWebhook(conf.WebhookURL, conf.WebhookEvents, conf.WebhookTimeout)
+		go g.webhook.run(g.shutdownCtx, g.log)
+	}
+	if conf.AdaptiveAppend {
+		g.adaptive = newAdaptiveChunker(conf.AdaptiveMinRows, conf.AdaptiveMaxRows, conf.AdaptiveTargetLatency)
+	}
+	if conf.FailoverTableID != "" {
+		g.failover = newFailoverDestination(conf.FailoverProjectID, conf.FailoverDatasetID, conf.FailoverTableID, conf.FailoverAfter, conf.FailoverProbeInterval)
+	}
+	if conf.RetryBudgetName != "" {
+		g.retryBudget = loadRetryBudget(conf.RetryBudgetName, conf.RetryBudgetRate, conf.RetryBudgetBurst)
+	}
+	if conf.BreakerThreshold > 0 {
+		g.breaker = newCircuitBreaker(conf.BreakerThreshold, conf.BreakerCooldown)
+	}
+	if conf.Dedupe {
+		g.deduper = newRowDeduper(conf.DedupeKeyColumns)
+	}
+	if conf.ResultQueueSize > 0 {
+		g.collector = newResultCollector(conf.ResultQueueSize)
+		go g.collector.run(g.confirmAppend)
+	}
+	if conf.SpillPath != "" {
+		spill, err := newSpillQueue(conf.SpillPath, conf.SpillMaxBytes)
+		if err != nil {
+			// Stop the goroutines started above.
+			_ = g.Close(context.Background())
+			return nil, err
+		}
+		spill.metrics = spillMetrics{
+			destination: g.destination,
+			depth:       mgr.Metrics().NewGauge("bigquery_stream_spill_depth_bytes", "destination"),
+			segments:    mgr.Metrics().NewGauge("bigquery_stream_spill_segments", "destination"),
+			age:         mgr.Metrics().NewGauge("bigquery_stream_spill_oldest_age_ns", "destination"),
+			replayed:    mgr.Metrics().NewCounter("bigquery_stream_spill_replayed_rows", "destination"),
+		}
+		spill.report()
+		g.spill = spill
+		go spill.replay(conf.SpillReplayInterval, g.schemaFingerprint, func(ctx context.Context, rows [][]byte) error {
+			failed, err := g.resolveRowErrors(ctx, rows, g.appendWithRetry(ctx, rows, 0))
+			for _, rowErr := range failed {
+				g.logRowError(g.log.Errorf, "spill:"+conversionErrorKey(rowErr), "dropping spilled row rejected by BigQuery: %v", rowErr)
+			}
+			return err
+		}, g.log)
 	}
 
 	return g, nil
 }
 
 func (g *gcpBigQueryOutput) Connect(ctx context.Context) (err error) {
+	// Writes report the output as not connected while a reconnect is in
+	// flight, wait for its outcome rather than connecting a second time.
+	g.connMut.RLock()
+	pending := g.reconnecting
+	g.connMut.RUnlock()
+	if pending != nil {
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
 	g.connMut.Lock()
 	defer g.connMut.Unlock()
+	if pending != nil && g.managedStream != nil {
+		return nil
+	}
+	ctx, span := g.tracer.Start(ctx, "gcp_bigquery_stream.connect")
+	defer func() {
+		endSpan(span, err)
+	}()
+	defer func() {
+		if err != nil {
+			g.setState(streamStateFailed)
+		} else {
+			g.setState(streamStateConnected)
+		}
+	}()
+
+	if g.breaker != nil {
+		if d := g.breaker.remaining(); d > 0 {
+			return fmt.Errorf("circuit breaker open, next probe in %v", d.Round(time.Second))
+		}
+		if g.managedStream != nil {
+			// The stream was not lost, writes only failed fast while the
+			// breaker was open.
+			return nil
+		}
+	}
 
+	credentials := g.conf.credentialsFingerprint()
 	var client *bigquery.Client
 	if client, err = g.clientURL.NewClient(ctx, g.conf); err != nil {
 		err = fmt.Errorf("error creating big query client: %w", err)
@@ -227,67 +1426,175 @@ func (g *gcpBigQueryOutput) Connect(ctx context.Context) (err error) {
 		}
 	}()
 
-	var mwClient *managedwriter.Client
-	if mwClient, err = g.mwClientURL.NewClient(ctx, g.conf); err != nil {
-		err = fmt.Errorf("error creating BigQuery managed writer client: %w", err)
-		return
-	}
-	defer func() {
+	if g.conf.ProjectID == bigquery.DetectProjectID {
+		// The table lives in the project detected from the credentials, which
+		// table paths need spelled out.
+		g.conf.ProjectID = client.Project()
+		if g.failover != nil && g.conf.FailoverProjectID == bigquery.DetectProjectID {
+			g.conf.FailoverProjectID = client.Project()
+			g.failover.setTable(g.conf.FailoverProjectID, g.conf.FailoverDatasetID, g.conf.FailoverTableID)
+		}
+		g.resolveDestination()
+		if g.spill != nil {
+			g.spill.mu.Lock()
+			g.spill.report()
+			g.spill.mu.Unlock()
+		}
+	}
+
+	var mwClient *managedwriter.Client
+	if mwClient, err = g.mwClientURL.NewClient(ctx, g.conf); err != nil {
+		err = fmt.Errorf("error creating BigQuery managed writer client: %w", err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			mwClient.Close()
+		}
+	}()
+
+	if g.conf.PermissionCheck {
+		if err = g.checkPermissions(ctx, client); err != nil {
+			return
+		}
+	}
+
+	ts, err := g.cachedTableSchema(ctx, client, mwClient)
+	if err != nil {
+		return err
+	}
+
+	td, err := loadTableDescriptor(ts)
+	if err != nil {
+		return err
+	}
+	g.dumpDescriptor(ts, td)
+	md, dp, fields := td.md, td.dp, td.fields
+
+	if g.conf.RawJSONColumn != "" {
+		if err = checkRawJSONColumns(g.conf, fields); err != nil {
+			return
+		}
+	}
+
+	ms, err := mwClient.NewManagedStream(ctx, g.streamOptions(ctx, dp)...)
+	if err != nil {
+		err = fmt.Errorf("error creating BigQuery managed stream: %w", err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			ms.Close()
+		}
+	}()
+
+	var sink *errorSink
+	if g.conf.ErrorsTable != "" {
+		if sink, err = g.openErrorSink(ctx, client, mwClient); err != nil {
+			return
+		}
+	}
+
+	// A failed reconnect leaves the previous clients behind, they are closed
+	// along with the streams opened on them once replaced.
+	oldClient, oldMWClient, oldStream := g.client, g.mwClient, g.managedStream
+	g.client = client
+	g.mwClient = mwClient
+	g.credentials = credentials
+	g.managedStream = ms
+	g.messageDescriptor = md
+	g.messagePool = newMessagePool(md)
+	g.descriptorProto = dp
+	g.schemaFields = fields
+	g.encoder = g.newEncoder(md)
+	if g.errorSink != nil {
+		g.errorSink.close()
+	}
+	g.errorSink = sink
+	if oldStream != nil {
+		oldStream.Close()
+	}
+	if oldMWClient != nil {
+		if g.failover != nil {
+			g.failover.close()
+		}
+		oldMWClient.Close()
+	}
+	if oldClient != nil {
+		oldClient.Close()
+	}
+
+	g.log.Infof("gcp bigquery managed writer connected - %s.%s.%s\n", client.Project(), g.conf.DatasetID, g.conf.TableID)
+	return nil
+}
+
+// tableSchema fetches the schema of the destination table. By default the
+// dataset and table metadata are read, which reports missing datasets and
+// tables clearly. With skip_existence_check the schema is instead read from
+// the default write stream, which only needs permission to write to the table.
+func (g *gcpBigQueryOutput) tableSchema(ctx context.Context, client *bigquery.Client, mwClient *managedwriter.Client) (*storage.TableSchema, error) {
+	return g.tableSchemaOf(ctx, client, mwClient, g.conf.TableID)
+}
+
+func (g *gcpBigQueryOutput) tableSchemaOf(ctx context.Context, client *bigquery.Client, mwClient *managedwriter.Client, tableID string) (*storage.TableSchema, error) {
+	if g.conf.SkipExistenceCheck {
+		ws, err := mwClient.GetWriteStream(ctx, &storage.GetWriteStreamRequest{
+			Name: fmt.Sprintf("%s/streams/_default", managedwriter.TableParentFromParts(g.conf.ProjectID, g.conf.DatasetID, tableID)),
+			View: storage.WriteStreamView_FULL,
+		})
 		if err != nil {
-			mwClient.Close()
+			return nil, fmt.Errorf("error reading table schema from write stream: %w", err)
 		}
-	}()
+		return ws.GetTableSchema(), nil
+	}
 
 	dataset := client.DatasetInProject(g.conf.ProjectID, g.conf.DatasetID)
-	if _, err = dataset.Metadata(ctx); err != nil {
+	if _, err := dataset.Metadata(ctx); err != nil {
 		if hasStatusCode(err, http.StatusNotFound) {
-			err = fmt.Errorf("dataset does not exist: %v", g.conf.DatasetID)
-		} else {
-			err = fmt.Errorf("error checking dataset existence: %w", err)
+			return nil, fmt.Errorf("dataset does not exist: %v", g.conf.DatasetID)
 		}
-		return
+		return nil, fmt.Errorf("error checking dataset existence: %w", err)
 	}
 
-	table := dataset.Table(g.conf.TableID)
-	metadata, err := table.Metadata(ctx)
+	metadata, err := dataset.Table(tableID).Metadata(ctx)
 	if err != nil {
 		if hasStatusCode(err, http.StatusNotFound) {
-			err = fmt.Errorf("table does not exist: %v", g.conf.TableID)
-		} else {
-			err = fmt.Errorf("error checking table existence: %w", err)
+			return nil, fmt.Errorf("table does not exist: %v", tableID)
 		}
-		return
-	}
-
-	md, dp, err := getDescriptor(metadata.Schema)
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("error checking table existence: %w", err)
 	}
+	return adapt.BQSchemaToStorageTableSchema(metadata.Schema)
+}
 
-	ms, err := mwClient.NewManagedStream(ctx,
+// streamOptions returns the writer options used whenever the managed stream
+// is (re)created.
+func (g *gcpBigQueryOutput) streamOptions(ctx context.Context, dp *descriptorpb.DescriptorProto) []managedwriter.WriterOption {
+	opts := []managedwriter.WriterOption{
 		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(
 			g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID)),
 		managedwriter.WithType(managedwriter.DefaultStream),
 		managedwriter.WithSchemaDescriptor(dp),
-	)
-	if err != nil {
-		err = fmt.Errorf("error creating BigQuery managed stream: %w", err)
-		return
+		managedwriter.WithTraceID(g.streamTraceID(ctx)),
 	}
-	defer func() {
-		if err != nil {
-			ms.Close()
-		}
-	}()
-
-	g.client = client
-	g.mwClient = mwClient
-	g.managedStream = ms
-	g.messageDescriptor = md
-	g.descriptorProto = dp
-
-	g.log.Infof("gcp bigquery managed writer connected - %s.%s.%s\n", client.Project(), g.conf.DatasetID, g.conf.TableID)
-	return nil
+	if g.conf.MissingValueInterpretation != storage.AppendRowsRequest_MISSING_VALUE_INTERPRETATION_UNSPECIFIED {
+		opts = append(opts, managedwriter.WithDefaultMissingValueInterpretation(g.conf.MissingValueInterpretation))
+	}
+	if g.conf.MaxInflightRequests > 0 {
+		opts = append(opts, managedwriter.WithMaxInflightRequests(g.conf.MaxInflightRequests))
+	}
+	if g.conf.MaxInflightBytes > 0 {
+		opts = append(opts, managedwriter.WithMaxInflightBytes(g.conf.MaxInflightBytes))
+	}
+	if g.conf.WriteRetries {
+		backoff := g.conf.ConnectBackoff
+		opts = append(opts,
+			managedwriter.EnableWriteRetries(true),
+			managedwriter.WithAppendRowsCallOption(gax.WithRetry(func() gax.Retryer {
+				return gax.OnCodes([]codes.Code{codes.Unavailable, codes.ResourceExhausted}, backoff)
+			})),
+		)
+	}
+	return opts
 }
 
 func hasStatusCode(err error, code int) bool {
@@ -298,13 +1605,8 @@ func hasStatusCode(err error, code int) bool {
 }
 
 // setupDynamicDescriptors aids testing when not using a supplied proto
-func getDescriptor(schema bigquery.Schema) (protoreflect.MessageDescriptor, *descriptorpb.DescriptorProto, error) {
-	convertedSchema, err := adapt.BQSchemaToStorageTableSchema(schema)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	descriptor, err := adapt.StorageSchemaToProto2Descriptor(convertedSchema, "root")
+func getDescriptor(schema *storage.TableSchema) (protoreflect.MessageDescriptor, *descriptorpb.DescriptorProto, error) {
+	descriptor, err := adapt.StorageSchemaToProto2Descriptor(schema, "root")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -319,105 +1621,381 @@ func getDescriptor(schema bigquery.Schema) (protoreflect.MessageDescriptor, *des
 	return md, dp, nil
 }
 
-func (g *gcpBigQueryOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
-	// Try to write the batch, with automatic reconnection on TTL expiration
-	return g.writeBatchWithRetry(ctx, batch, 0)
+// newEncoder returns a fast encoder for md when enabled and supported.
+func (g *gcpBigQueryOutput) newEncoder(md protoreflect.MessageDescriptor) *fastEncoder {
+	if !g.conf.FastEncoding {
+		return nil
+	}
+	enc, ok := newFastEncoder(md, g.umo.DiscardUnknown, g.conf.TrustedSource, g.mUnknownFields)
+	if !ok {
+		g.log.Infof("table %v contains field types unsupported by the fast encoder, using protojson conversion", g.conf.TableID)
+		return nil
+	}
+	return enc
+}
+
+// convertMessage turns a raw JSON message into serialized proto row bytes
+// matching the table descriptor.
+func (g *gcpBigQueryOutput) convertMessage(msgBytes []byte, pool *messagePool, fields map[string]*schemaField, enc *fastEncoder) ([]byte, error) {
+	if !g.conf.TrustedSource {
+		if err := checkMessageGuards(msgBytes, g.conf.MaxMessageBytes, g.conf.MaxJSONDepth); err != nil {
+			return nil, err
+		}
+	}
+	if enc != nil {
+		b, err := g.encodeFast(msgBytes, fields, enc)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) <= g.conf.MaxRowBytes {
+			return b, nil
+		}
+		// Oversized rows take the dynamic path below where they can be
+		// truncated field by field.
+	}
+	msgBytes, err := g.transformer.transform(msgBytes, fields)
+	if err != nil {
+		return nil, err
+	}
+	message := pool.get()
+	defer pool.put(message)
+	if err := g.umo.Unmarshal(msgBytes, message); err != nil {
+		return nil, err
+	}
+	b, err := g.mo.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > g.conf.MaxRowBytes {
+		return g.handleOversizedRow(message, b)
+	}
+	return b, nil
+}
+
+func (g *gcpBigQueryOutput) encodeFast(msgBytes []byte, fields map[string]*schemaField, enc *fastEncoder) ([]byte, error) {
+	if enc.flat && !g.transformer.enabled() {
+		if b, err := enc.encodeFlat(msgBytes); !errors.Is(err, errFlatFallback) {
+			return b, err
+		}
+	}
+	row, err := decodeJSONRow(msgBytes)
+	if err != nil {
+		return nil, err
+	}
+	if g.transformer.enabled() {
+		if err := g.transformer.transformObject(row, fields); err != nil {
+			return nil, err
+		}
+	}
+	return enc.encode(nil, row)
 }
 
-func (g *gcpBigQueryOutput) writeBatchWithRetry(ctx context.Context, batch service.MessageBatch, retryCount int) error {
-	const maxRetries = 2
+func (g *gcpBigQueryOutput) WriteBatch(ctx context.Context, batch service.MessageBatch) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(g.shutdownCtx, cancel)
+	defer stop()
+
+	// The deadline bounds the appends of the batch and its waits for
+	// reconnects, which open their streams and clients on the lifetime context
+	// of the output so that they survive the batch.
+	parent := ctx
+	if g.conf.BatchDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.conf.BatchDeadline)
+		defer cancel()
+	}
+	g.throughput.inFlight.Add(1)
+	defer g.throughput.inFlight.Add(-1)
+	g.throughput.messages.Add(int64(len(batch)))
+
+	stats := &batchStats{}
+	started := time.Now()
+	ctx, span := g.startBatchSpan(withBatchStats(ctx, stats), batch)
+	err := g.writeBatch(ctx, batch)
+	endSpan(span, err)
+	g.logBatchSummary(batch, stats, started, err)
+	if g.isPermanentFailure(err) {
+		g.emit(eventBatchFailed, err, failedMessages(batch, err))
+	}
+	g.countRejected(batch, err)
+	var batchErr *service.BatchError
+	isBatchErr := errors.As(err, &batchErr)
+	if err != nil && ctx.Err() != nil && parent.Err() == nil {
+		g.log.Warnf("batch of %d messages not written within deadline of %v: %v", len(batch), g.conf.BatchDeadline, err)
+		if isBatchErr {
+			// Nack the whole batch so that it is redelivered.
+			err = batchErr.Unwrap()
+		}
+		return fmt.Errorf("batch deadline of %v exceeded: %w", g.conf.BatchDeadline, err)
+	}
+	if !isBatchErr {
+		return err
+	}
+	if g.conf.AckGranularity == "batch" {
+		// Nack every message of the batch rather than only the failed ones.
+		return batchErr.Unwrap()
+	}
+	if g.conf.ErrorsTable == "" {
+		return err
+	}
+	return g.divertRejected(ctx, batch, batchErr)
+}
 
+func (g *gcpBigQueryOutput) writeBatch(ctx context.Context, batch service.MessageBatch) error {
 	g.connMut.RLock()
-	ms := g.managedStream
+	// The stream is unset while a reconnect is in flight, so new batches are
+	// refused and the runtime reports the output as disconnected until the
+	// reconnect completes. Appends already under way wait for it instead.
+	connected := g.managedStream != nil
+	pool := g.messagePool
+	fields := g.schemaFields
+	enc := g.encoder
 	g.connMut.RUnlock()
-	if ms == nil {
+	if !connected {
+		return service.ErrNotConnected
+	}
+
+	convStart := time.Now()
+	rows, indexes, batchErr := g.convertBatch(batch, pool, fields, enc)
+	convTime := time.Since(convStart)
+	if s := batchStatsFrom(ctx); s != nil {
+		s.converted.Store(int64(len(rows)))
+	}
+	g.mConversionLatency.Timing(convTime.Nanoseconds())
+	if g.conf.SlowConversionThreshold > 0 && convTime > g.conf.SlowConversionThreshold {
+		g.log.Warnf("converting batch of %d messages took %v, exceeding slow_conversion_threshold of %v", len(batch), convTime, g.conf.SlowConversionThreshold)
+	}
+	if g.conf.Strict && batchErr != nil {
+		return fmt.Errorf("%d of %d messages are invalid, batch not written in strict mode: %w", batchErr.IndexedErrors(), len(batch), batchErr.Unwrap())
+	}
+	if len(rows) == 0 {
+		if batchErr != nil {
+			return batchErr
+		}
+		return nil
+	}
+
+	if g.breaker != nil && !g.breaker.allow() {
 		return service.ErrNotConnected
 	}
 
+	maxRows := g.conf.MaxRowsPerAppend
+	if g.adaptive != nil {
+		maxRows = g.adaptive.limit()
+	}
+	chunks := chunkRows(rows, maxRows, g.conf.MaxBytesPerAppend)
+	if len(chunks) > 1 {
+		g.mAppendSplits.Incr(int64(len(chunks) - 1))
+		g.log.Debugf("splitting %d rows into %d appends to stay within append limits", len(rows), len(chunks))
+	}
+
+	if g.collector != nil && (g.failover == nil || !g.failover.isActive()) {
+		return g.writePipelined(ctx, batch, rows, indexes, chunks, batchErr)
+	}
+
+	// Chunks are appended in order and the first failure stops the batch, so
+	// rows are never written out of order. Rows are converted once and reused
+	// as is when an append is retried after a reconnect. Rows rejected
+	// individually by BigQuery are reported as failed on their own, and the
+	// rest of their chunk is appended again.
+	for ci, c := range chunks {
+		start := time.Now()
+		failed, err := g.resolveRowErrors(ctx, rows[c.start:c.end], g.appendWithRetry(ctx, rows[c.start:c.end], 0))
+		g.mAppendLatency.Timing(time.Since(start).Nanoseconds())
+		if g.adaptive != nil {
+			g.adaptive.observe(c.end-c.start, time.Since(start), err)
+		}
+		g.recordAppend(ctx, err)
+		for pos, rowErr := range failed {
+			batchErr = failBatchIndex(batchErr, batch, indexes[c.start+pos], rowErr)
+		}
+		if g.errorClass(err) == errorFatal {
+			// Retrying the rows will never succeed, so they are rejected
+			// right away and the remaining chunks are still appended.
+			var rejected map[int]error
+			if rejected, err = g.rejectFatalChunk(ctx, rows[c.start:c.end], err); err == nil {
+				for pos, rowErr := range rejected {
+					batchErr = failBatchIndex(batchErr, batch, indexes[c.start+pos], rowErr)
+				}
+				continue
+			}
+		}
+		if err = g.spillRows(rows[c.start:c.end], err); err == nil {
+			continue
+		}
+		if ci == 0 && batchErr == nil {
+			for _, msg := range batch {
+				annotateError(msg, err)
+			}
+			return err
+		}
+		for _, idx := range indexes[c.start:] {
+			batchErr = failBatchIndex(batchErr, batch, idx, err)
+		}
+		return batchErr
+	}
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// convertBatch serializes every message of the batch into proto rows, along
+// with the batch index of each row. Rows that fail conversion are recorded in
+// the returned batch error.
+func (g *gcpBigQueryOutput) convertBatch(batch service.MessageBatch, pool *messagePool, fields map[string]*schemaField, enc *fastEncoder) ([][]byte, []int, *service.BatchError) {
 	var batchErr *service.BatchError
+	var summary conversionSummary
 	setErr := func(idx int, err error) {
-		if batchErr == nil {
-			batchErr = service.NewBatchError(batch, err)
-		}
-		batchErr = batchErr.Failed(idx, err)
+		summary.add(err)
+		batchErr = failBatchIndex(batchErr, batch, idx, &rowRejectedError{err: err})
 	}
 
-	g.log.Debugf("creating pb messages for batch length %d\n", len(batch))
 	var rows [][]byte
+	var indexes []int
+	var seen map[string]struct{}
+	if g.deduper != nil {
+		seen = make(map[string]struct{}, len(batch))
+	}
 	for i, msg := range batch {
 		msgBytes, err := msg.AsBytes()
 		if err != nil {
 			setErr(i, err)
 			continue
 		}
-		message := dynamicpb.NewMessage(g.messageDescriptor)
-		if err := g.umo.Unmarshal(msgBytes, message); err != nil {
-			setErr(i, err)
-			continue
+		if g.conf.RawJSONColumn != "" {
+			if msgBytes, err = g.rawJSONRow(msg, msgBytes); err != nil {
+				setErr(i, err)
+				continue
+			}
+		}
+		var key string
+		if seen != nil {
+			if key, err = g.deduper.key(msgBytes); err != nil {
+				setErr(i, err)
+				continue
+			}
+			if _, dup := seen[key]; dup {
+				g.mDuplicates.Incr(1)
+				continue
+			}
 		}
-		b, err := proto.Marshal(message)
+		b, err := g.convertMessage(msgBytes, pool, fields, enc)
 		if err != nil {
+			if errors.Is(err, errRowDropped) {
+				continue
+			}
 			setErr(i, err)
 			continue
 		}
+		if seen != nil {
+			// Only rows that are written make later ones duplicates.
+			seen[key] = struct{}{}
+		}
 		rows = append(rows, b)
+		indexes = append(indexes, i)
+	}
+	if summary.total > 0 {
+		g.mConversionErrors.Incr(int64(summary.total))
+		g.logRowError(g.log.Warnf, "conversion:"+summary.key(), "%d of %d messages failed conversion: %v", summary.total, len(batch), &summary)
 	}
-	g.log.Debugf("created %d pb messages, errors: %b\n", len(rows), batchErr != nil)
+	return rows, indexes, batchErr
+}
 
-	if len(rows) == 0 {
-		if batchErr != nil {
-			return batchErr
-		}
-		return nil
+// failBatchIndex marks the message at idx as failed and annotates it with
+// the details of err.
+func failBatchIndex(batchErr *service.BatchError, batch service.MessageBatch, idx int, err error) *service.BatchError {
+	annotateError(batch[idx], err)
+	if batchErr == nil {
+		batchErr = service.NewBatchError(batch, err)
+	}
+	return batchErr.Failed(idx, err)
+}
+
+func (g *gcpBigQueryOutput) appendWithRetry(ctx context.Context, rows [][]byte, retryCount int) error {
+	if g.failover != nil {
+		return g.appendPrimary(ctx, rows, retryCount)
 	}
+	return g.appendAttempt(ctx, rows, retryCount, time.Now())
+}
 
-	result, err := ms.AppendRows(ctx, rows)
+func (g *gcpBigQueryOutput) appendAttempt(ctx context.Context, rows [][]byte, retryCount int, started time.Time) error {
+	ms, err := g.stream(ctx)
 	if err != nil {
-		// Check if this is a connection error that requires reconnection
-		if g.isReconnectableError(err) && retryCount < maxRetries {
-			g.log.Warnf("bigquery stream connection error, attempting to reconnect (attempt %d/%d):", retryCount+1, maxRetries)
-			g.logErrorDetails(err)
-
-			// Attempt to reconnect
-			if reconnectErr := g.reconnect(ctx); reconnectErr != nil {
-				g.log.Errorf("failed to reconnect BigQuery stream: %v", reconnectErr)
-				return fmt.Errorf("connection error reconnect failed: %w", reconnectErr)
-			}
+		return err
+	}
 
-			// Retry the operation
-			return g.writeBatchWithRetry(ctx, batch, retryCount+1)
+	result, err := g.appendRows(withAppendAttempt(ctx, retryCount), ms, rows)
+	if err != nil {
+		g.reportQuota(err)
+		if isTableReplacedError(err) {
+			return g.resyncAfter(ctx, ms, err)
+		}
+		if g.errorClass(err) == errorRetryable && g.retryAllowed(retryCount, started) {
+			return g.retryQuotaAppend(ctx, rows, retryCount, started, err)
+		}
+		if g.canRetry(err, retryCount, started) {
+			return g.retryAppend(ctx, ms, rows, retryCount, started, err, "")
 		}
 		return err
 	}
 
-	o, err := result.GetResult(ctx)
+	o, err := g.getResult(ctx, result)
 	if err != nil {
-		// Check if this is a connection error that requires reconnection
-		if g.isReconnectableError(err) && retryCount < maxRetries {
-			g.log.Warnf("bigquery stream connection error on GetResult, attempting to reconnect (attempt %d/%d):", retryCount+1, maxRetries)
-			g.logErrorDetails(err)
-
-			// Attempt to reconnect
-			if reconnectErr := g.reconnect(ctx); reconnectErr != nil {
-				g.log.Errorf("failed to reconnect BigQuery stream: %v", reconnectErr)
-				return fmt.Errorf("connection error reconnect failed: %w", reconnectErr)
-			}
-
-			// Retry the operation
-			return g.writeBatchWithRetry(ctx, batch, retryCount+1)
+		g.reportQuota(err)
+		if isTableReplacedError(err) {
+			return g.resyncAfter(ctx, ms, err)
 		}
-		return err
+		if g.errorClass(err) == errorRetryable && g.retryAllowed(retryCount, started) {
+			return g.retryQuotaAppend(ctx, rows, retryCount, started, err)
+		}
+		if g.canRetry(err, retryCount, started) {
+			return g.retryAppend(ctx, ms, rows, retryCount, started, err, " on GetResult")
+		}
+		return withRowErrors(ctx, result, err)
 	}
 	if o != managedwriter.NoStreamOffset {
 		return fmt.Errorf("offset mismatch, got %d want %d", o, managedwriter.NoStreamOffset)
 	}
+	g.recordAppended(ctx, rows)
+	return nil
+}
 
-	if batchErr != nil {
-		return batchErr
+func (g *gcpBigQueryOutput) canRetry(err error, retryCount int, started time.Time) bool {
+	return g.errorClass(err) == errorReconnectable && g.retryAllowed(retryCount, started)
+}
+
+// retryAppend waits for the retry backoff, reconnects the failed stream and
+// appends the rows again.
+func (g *gcpBigQueryOutput) retryAppend(ctx context.Context, ms *managedwriter.ManagedStream, rows [][]byte, retryCount int, started time.Time, err error, stage string) error {
+	g.recordRetry(ctx)
+	if isConnectionCycling(err) {
+		// BigQuery routinely drains and cycles connections, so the append is
+		// resubmitted on a new connection straight away.
+		g.log.Infof("bigquery stream connection cycled by server%s, reconnecting and resubmitting append: %v", stage, err)
+	} else {
+		g.log.Warnf("bigquery stream connection error%s, attempting to reconnect (attempt %d/%d):", stage, retryCount+1, g.conf.Retry.maxAttempts-1)
+		g.logErrorDetails(err)
+
+		if err := g.conf.Retry.wait(ctx, retryCount); err != nil {
+			return err
+		}
 	}
 
-	g.log.Debugf("%d rows written\n", len(rows))
-	return nil
+	// Attempt to reconnect, recreating the clients when they are broken
+	reconnect := g.reconnect
+	if isClientError(err) {
+		reconnect = g.recreateClients
+	}
+	if reconnectErr := reconnect(ctx, ms); reconnectErr != nil {
+		g.log.Errorf("failed to reconnect BigQuery stream: %v", reconnectErr)
+		return fmt.Errorf("connection error reconnect failed: %w", reconnectErr)
+	}
+
+	// Retry the operation
+	return g.appendAttempt(ctx, rows, retryCount+1, started)
 }
 
 // isReconnectableError checks if the error is related to connection issues that can be resolved by reconnecting
@@ -465,10 +2043,29 @@ func (g *gcpBigQueryOutput) isReconnectableError(err error) bool {
 	if strings.Contains(errStr, "server_shutting_down") {
 		return true
 	}
+	if isConnectionCycling(err) {
+		return true
+	}
+	if isClientError(err) {
+		return true
+	}
 
 	return false
 }
 
+// isConnectionCycling reports whether the error was caused by the server
+// draining the connection, e.g. with an HTTP/2 GOAWAY, which is an expected
+// event rather than a failure.
+func isConnectionCycling(err error) bool {
+	errStr := strings.ToLower(err.Error())
+	for _, pattern := range []string{"goaway", "transport is closing", "connection draining", "server_shutting_down"} {
+		if strings.Contains(errStr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
 // logErrorDetails provides detailed logging for structured error information
 func (g *gcpBigQueryOutput) logErrorDetails(err error) {
 	if err == nil {
@@ -498,38 +2095,230 @@ func (g *gcpBigQueryOutput) logErrorDetails(err error) {
 	}
 }
 
-// reconnect closes the existing stream and creates a new one
-func (g *gcpBigQueryOutput) reconnect(ctx context.Context) error {
-	g.connMut.Lock()
-	defer g.connMut.Unlock()
+// stream returns the current managed stream, waiting for an in-flight
+// reconnect to complete first.
+func (g *gcpBigQueryOutput) stream(ctx context.Context) (*managedwriter.ManagedStream, error) {
+	g.connMut.RLock()
+	ms, pending := g.managedStream, g.reconnecting
+	g.connMut.RUnlock()
+	if pending != nil {
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		g.connMut.RLock()
+		var err error
+		ms, err = g.managedStream, g.reconnectErr
+		g.connMut.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ms == nil {
+		return nil, service.ErrNotConnected
+	}
+	return ms, nil
+}
 
-	// Close existing stream
-	if g.managedStream != nil {
-		g.managedStream.Close()
+// reconnect replaces the failed managed stream. Concurrent callers share a
+// single reconnect: the first caller performs it while the others wait for its
+// outcome, and callers whose stream has already been replaced return
+// immediately. The lock is only held to swap state, so writers on a healthy
+// stream are never blocked by the reconnect delay.
+func (g *gcpBigQueryOutput) reconnect(ctx context.Context, failed *managedwriter.ManagedStream) error {
+	return g.replaceStream(ctx, failed, false, false)
+}
+
+// resync replaces the failed managed stream like reconnect, but first reloads
+// the table schema, for when the table was deleted or recreated underneath
+// the stream.
+func (g *gcpBigQueryOutput) resync(ctx context.Context, failed *managedwriter.ManagedStream) error {
+	return g.replaceStream(ctx, failed, true, false)
+}
+
+// replaceStream performs a reconnect, first reloading the table schema when
+// refresh is set and creating new clients when recreate is set.
+func (g *gcpBigQueryOutput) replaceStream(ctx context.Context, failed *managedwriter.ManagedStream, refresh, recreate bool) error {
+	g.connMut.Lock()
+	pending := g.reconnecting
+	if pending == nil {
+		if g.managedStream != failed {
+			// Another writer already reconnected since this stream failed.
+			g.connMut.Unlock()
+			return nil
+		}
+		pending = make(chan struct{})
+		g.reconnecting = pending
+		g.setState(streamStateReconnecting)
+		old := g.managedStream
 		g.managedStream = nil
+		client, mwClient, dp := g.client, g.mwClient, g.descriptorProto
+		credentials := g.credentials
+		delay := g.nextReconnectDelay()
+		go g.runReconnect(trace.LinkFromContext(ctx), pending, old, client, mwClient, dp, credentials, delay, refresh, recreate)
+	}
+	g.connMut.Unlock()
+
+	// Every writer, including the one that started the reconnect, only waits
+	// for its outcome on its own context.
+	select {
+	case <-pending:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+	g.connMut.RLock()
+	defer g.connMut.RUnlock()
+	return g.reconnectErr
+}
 
-	// Add a small delay to avoid rapid reconnection attempts
-	time.Sleep(time.Second)
+// runReconnect performs a reconnect started by replaceStream and closes done
+// once its outcome is stored. The streams and clients it opens outlive the
+// writers waiting for it, so it runs on the lifetime context of the output
+// rather than on the context of the writer that started it.
+func (g *gcpBigQueryOutput) runReconnect(link trace.Link, done chan struct{}, old *managedwriter.ManagedStream, client *bigquery.Client, mwClient *managedwriter.Client, dp *descriptorpb.DescriptorProto, credentials string, delay time.Duration, refresh, recreate bool) {
+	ctx, span := g.tracer.Start(g.shutdownCtx, "gcp_bigquery_stream.reconnect", trace.WithLinks(link))
 
-	// Create new managed stream
-	ms, err := g.mwClient.NewManagedStream(ctx,
-		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(
-			g.conf.ProjectID, g.conf.DatasetID, g.conf.TableID)),
-		managedwriter.WithType(managedwriter.DefaultStream),
-		managedwriter.WithSchemaDescriptor(g.descriptorProto),
-	)
+	if old != nil {
+		old.Close()
+	}
+
+	var td *tableDescriptor
+	var err error
+	if delay > 0 {
+		g.log.Infof("waiting %v before reconnecting BigQuery managed stream", delay)
+		err = sleep(ctx, delay)
+	}
+
+	oldClient, oldMWClient := client, mwClient
+	recreated := false
+	if err == nil {
+		if current := g.conf.credentialsFingerprint(); current != credentials {
+			g.log.Infof("credentials of table %v changed, recreating BigQuery clients", g.conf.TableID)
+			credentials, recreate = current, true
+		}
+	}
+	if recreate && err == nil {
+		if client, mwClient, err = g.newClients(ctx); err == nil {
+			recreated = true
+		}
+	}
+	if refresh && err == nil {
+		if td, err = g.refreshTableDescriptor(ctx, client, mwClient); err == nil {
+			dp = td.dp
+		}
+	}
+	var ms *managedwriter.ManagedStream
+	if err == nil {
+		ms, err = g.openStream(ctx, mwClient, dp)
+	}
+	var sink *errorSink
+	if recreated && err == nil && g.conf.ErrorsTable != "" {
+		if sink, err = g.openErrorSink(ctx, client, mwClient); err != nil {
+			ms.Close()
+		}
+	}
+	if recreated && err != nil {
+		client.Close()
+		mwClient.Close()
+	}
+
+	var oldSink *errorSink
+	g.connMut.Lock()
+	if err == nil {
+		g.managedStream = ms
+		if td != nil {
+			g.messageDescriptor = td.md
+			g.messagePool = newMessagePool(td.md)
+			g.descriptorProto = td.dp
+			g.schemaFields = td.fields
+			g.encoder = g.newEncoder(td.md)
+		}
+		if recreated {
+			g.client, g.mwClient = client, mwClient
+			g.credentials = credentials
+			oldSink, g.errorSink = g.errorSink, sink
+		}
+	}
+	g.reconnectErr = err
+	g.reconnecting = nil
+	close(done)
+	g.connMut.Unlock()
+	endSpan(span, err)
+
+	g.emit(eventReconnect, err, 0)
 	if err != nil {
-		return fmt.Errorf("error creating new BigQuery managed stream: %w", err)
+		g.setState(streamStateFailed)
+		return
+	}
+	g.setState(streamStateConnected)
+	g.mReconnects.Incr(1)
+	if recreated {
+		// Streams opened on the old clients are closed along with them.
+		if oldSink != nil {
+			oldSink.close()
+		}
+		if g.failover != nil {
+			g.failover.close()
+		}
+		if oldClient != nil {
+			oldClient.Close()
+		}
+		if oldMWClient != nil {
+			oldMWClient.Close()
+		}
+		g.log.Infof("successfully recreated BigQuery clients and managed stream - %s.%s.%s", client.Project(), g.conf.DatasetID, g.conf.TableID)
+		return
+	}
+	if refresh {
+		g.log.Infof("successfully resynced BigQuery table schema and managed stream - %s.%s.%s", client.Project(), g.conf.DatasetID, g.conf.TableID)
+		return
 	}
+	g.log.Infof("successfully reconnected BigQuery managed stream - %s.%s.%s", client.Project(), g.conf.DatasetID, g.conf.TableID)
+}
 
-	g.managedStream = ms
-	g.log.Infof("successfully reconnected BigQuery managed stream - %s.%s.%s", g.client.Project(), g.conf.DatasetID, g.conf.TableID)
+// nextReconnectDelay returns the delay before the next reconnect, which is
+// zero for an isolated reconnect and grows exponentially while reconnects
+// keep happening within the reset window. Must be called with connMut held.
+func (g *gcpBigQueryOutput) nextReconnectDelay() time.Duration {
+	if time.Since(g.lastReconnect) > g.conf.ReconnectResetAfter {
+		g.reconnectAttempts = 0
+	}
+	var delay time.Duration
+	if g.reconnectAttempts > 0 {
+		delay = g.conf.ReconnectBackoff.backoff(g.reconnectAttempts - 1)
+	}
+	g.reconnectAttempts++
+	g.lastReconnect = time.Now()
+	return delay
+}
 
-	return nil
+// openStream opens a managed stream on mwClient. The stream keeps ctx for its
+// lifetime, so it must not be the context of a single batch.
+func (g *gcpBigQueryOutput) openStream(ctx context.Context, mwClient *managedwriter.Client, dp *descriptorpb.DescriptorProto) (*managedwriter.ManagedStream, error) {
+	if mwClient == nil {
+		return nil, service.ErrNotConnected
+	}
+
+	ms, err := mwClient.NewManagedStream(ctx, g.streamOptions(ctx, dp)...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating new BigQuery managed stream: %w", err)
+	}
+	return ms, nil
 }
 
 func (g *gcpBigQueryOutput) Close(ctx context.Context) error {
+	g.setState(streamStateDraining)
+	g.shutdown()
+	if g.collector != nil {
+		g.collector.close()
+	}
+	if g.spill != nil {
+		g.spill.close()
+	}
+	if g.failover != nil {
+		g.failover.close()
+	}
 	g.connMut.Lock()
 	if g.client != nil {
 		g.client.Close()
@@ -537,12 +2326,17 @@ func (g *gcpBigQueryOutput) Close(ctx context.Context) error {
 	}
 	if g.mwClient != nil {
 		g.mwClient.Close()
-		g.client = nil
+		g.mwClient = nil
 	}
 	if g.managedStream != nil {
 		g.managedStream.Close()
 		g.managedStream = nil
 	}
+	if g.errorSink != nil {
+		g.errorSink.close()
+		g.errorSink = nil
+	}
 	g.connMut.Unlock()
+	g.setState("")
 	return nil
 }